@@ -1,13 +1,105 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/BurntSushi/toml"
+	"github.com/invopop/jsonschema"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 )
 
+const (
+	DefaultCreateTimeout    = 5 * time.Minute
+	DefaultRetryMaxAttempts = 5
+	DefaultRetryMaxDelay    = 30 * time.Second
+	DefaultStuckTimeout     = 15 * time.Minute
+	DefaultShutdownTimeout  = time.Minute
+)
+
+// Locations is an ordered list of locations, the first one being preferred.
+// It can be decoded from either a single location name or a list of names.
+type Locations []string
+
+func (l *Locations) UnmarshalTOML(data any) error {
+	switch value := data.(type) {
+	case string:
+		*l = Locations{value}
+	case []any:
+		locations := make(Locations, 0, len(value))
+		for _, item := range value {
+			location, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("invalid location: %v", item)
+			}
+			locations = append(locations, location)
+		}
+		*l = locations
+	default:
+		return fmt.Errorf("invalid location: %v", data)
+	}
+	return nil
+}
+
+func (l *Locations) UnmarshalJSON(data []byte) error {
+	var location string
+	if err := json.Unmarshal(data, &location); err == nil {
+		*l = Locations{location}
+		return nil
+	}
+	var locations []string
+	if err := json.Unmarshal(data, &locations); err != nil {
+		return fmt.Errorf("invalid location: %w", err)
+	}
+	*l = locations
+	return nil
+}
+
+func (Locations) JSONSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		OneOf: []*jsonschema.Schema{
+			{Type: "string"},
+			{Type: "array", Items: &jsonschema.Schema{Type: "string"}},
+		},
+	}
+}
+
+// Project is a Hetzner project, with its own token and default location,
+// where pools can create servers instead of the default project.
+type Project struct {
+	Location       Locations `toml:"location"`
+	Token          string    `toml:"token"`
+	TokenEnv       string    `toml:"token_env"`
+	TokenFile      string    `toml:"token_file"`
+	EncryptedToken string    `toml:"encrypted_token"`
+	TokenKeyFile   string    `toml:"token_key_file"`
+	TokenKeyEnv    string    `toml:"token_key_env"`
+}
+
+var projectNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9_-]*[a-zA-Z0-9])?$`)
+
 type Config struct {
-	Location string `toml:"location"`
-	Token    string `toml:"token"`
+	Location         Locations                 `toml:"location"`
+	Token            string                    `toml:"token"`
+	TokenEnv         string                    `toml:"token_env"`
+	TokenFile        string                    `toml:"token_file"`
+	EncryptedToken   string                    `toml:"encrypted_token"`
+	TokenKeyFile     string                    `toml:"token_key_file"`
+	TokenKeyEnv      string                    `toml:"token_key_env"`
+	WaitForCreate    bool                      `toml:"wait_for_create"`
+	CreateTimeout    time.Duration             `toml:"create_timeout"`
+	RetryMaxAttempts int                       `toml:"retry_max_attempts"`
+	RetryMaxDelay    time.Duration             `toml:"retry_max_delay"`
+	StuckTimeout     time.Duration             `toml:"stuck_timeout"`
+	ShutdownTimeout  time.Duration             `toml:"shutdown_timeout"`
+	Labels           map[string]string         `toml:"labels"`
+	Defaults         map[string]any            `toml:"defaults"`
+	Profiles         map[string]map[string]any `toml:"profiles"`
+	Projects         map[string]Project        `toml:"projects"`
 }
 
 func NewConfig(cfgFile string) (*Config, error) {
@@ -23,12 +115,231 @@ func NewConfig(cfgFile string) (*Config, error) {
 }
 
 func (c *Config) Validate() error {
-	if c.Token == "" {
-		return fmt.Errorf("missing token")
+	if err := c.validateToken(); err != nil {
+		return err
 	}
 
-	if c.Location == "" {
+	if len(c.Location) == 0 {
 		return fmt.Errorf("missing location")
 	}
+	for _, location := range c.Location {
+		if location == "" {
+			return fmt.Errorf("invalid empty location")
+		}
+	}
+
+	if c.CreateTimeout < 0 {
+		return fmt.Errorf("invalid create_timeout: %s", c.CreateTimeout)
+	}
+
+	if c.RetryMaxAttempts < 0 {
+		return fmt.Errorf("invalid retry_max_attempts: %d", c.RetryMaxAttempts)
+	}
+
+	if c.RetryMaxDelay < 0 {
+		return fmt.Errorf("invalid retry_max_delay: %s", c.RetryMaxDelay)
+	}
+
+	if c.StuckTimeout < 0 {
+		return fmt.Errorf("invalid stuck_timeout: %s", c.StuckTimeout)
+	}
+
+	if c.ShutdownTimeout < 0 {
+		return fmt.Errorf("invalid shutdown_timeout: %s", c.ShutdownTimeout)
+	}
+
+	if err := ValidateLabels(c.Labels); err != nil {
+		return fmt.Errorf("invalid labels: %w", err)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.Projects)) {
+		if err := c.validateProject(name); err != nil {
+			return fmt.Errorf("invalid project %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateProject(name string) error {
+	if !projectNameRegexp.MatchString(name) {
+		return fmt.Errorf("name must start and end with an alphanumeric character and only contain alphanumerics, '-' or '_'")
+	}
+	for _, location := range c.Projects[name].Location {
+		if location == "" {
+			return fmt.Errorf("invalid empty location")
+		}
+	}
+	project, err := c.ProjectConfig(name)
+	if err != nil {
+		return err
+	}
+	return project.validateToken()
+}
+
+// ProjectConfig returns the config to use for the servers of the named
+// project: the token and, when set, the location of the project replace the
+// ones of c. An empty name returns c itself, the default project.
+func (c *Config) ProjectConfig(name string) (*Config, error) {
+	if name == "" {
+		return c, nil
+	}
+	project, ok := c.Projects[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown project %q", name)
+	}
+	cfg := *c
+	cfg.Token = project.Token
+	cfg.TokenEnv = project.TokenEnv
+	cfg.TokenFile = project.TokenFile
+	cfg.EncryptedToken = project.EncryptedToken
+	cfg.TokenKeyFile = project.TokenKeyFile
+	cfg.TokenKeyEnv = project.TokenKeyEnv
+	if len(project.Location) > 0 {
+		cfg.Location = project.Location
+	}
+	cfg.Projects = nil
+	return &cfg, nil
+}
+
+// validateToken checks that exactly one token source is set and that the token
+// can be read from it. Errors never contain the token itself.
+func (c *Config) validateToken() error {
+	sources := 0
+	for _, source := range []string{c.Token, c.TokenEnv, c.TokenFile, c.EncryptedToken} {
+		if source != "" {
+			sources++
+		}
+	}
+	switch sources {
+	case 0:
+		return fmt.Errorf("missing token: one of token, token_env, token_file or encrypted_token must be set")
+	case 1:
+	default:
+		return fmt.Errorf("token, token_env, token_file and encrypted_token are mutually exclusive")
+	}
+
+	switch {
+	case c.EncryptedToken == "" && (c.TokenKeyFile != "" || c.TokenKeyEnv != ""):
+		return fmt.Errorf("token_key_file and token_key_env can only be set with encrypted_token")
+	case c.EncryptedToken != "" && c.TokenKeyFile == "" && c.TokenKeyEnv == "":
+		return fmt.Errorf("encrypted_token requires one of token_key_file or token_key_env")
+	case c.TokenKeyFile != "" && c.TokenKeyEnv != "":
+		return fmt.Errorf("token_key_file and token_key_env are mutually exclusive")
+	}
+
+	if _, err := c.GetToken(); err != nil {
+		return err
+	}
 	return nil
 }
+
+// GetToken returns the Hetzner API token from the configured source.
+func (c *Config) GetToken() (string, error) {
+	switch {
+	case c.Token != "":
+		return c.Token, nil
+	case c.TokenEnv != "":
+		token := strings.TrimSpace(os.Getenv(c.TokenEnv))
+		if token == "" {
+			return "", fmt.Errorf("environment variable %s from token_env is not set", c.TokenEnv)
+		}
+		return token, nil
+	case c.TokenFile != "":
+		return readSecretFile("token_file", c.TokenFile)
+	case c.EncryptedToken != "":
+		key, err := LoadTokenKey(c.TokenKeyFile, c.TokenKeyEnv)
+		if err != nil {
+			return "", err
+		}
+		token, err := DecryptToken(c.EncryptedToken, key)
+		if err != nil {
+			return "", fmt.Errorf("invalid encrypted_token: %w", err)
+		}
+		return token, nil
+	}
+	return "", fmt.Errorf("missing token")
+}
+
+// LoadTokenKey reads the token encryption key from the environment variable
+// keyEnv when set, or from keyFile otherwise.
+func LoadTokenKey(keyFile, keyEnv string) ([]byte, error) {
+	if keyEnv != "" {
+		encoded := os.Getenv(keyEnv)
+		if strings.TrimSpace(encoded) == "" {
+			return nil, fmt.Errorf("environment variable %s from token_key_env is not set", keyEnv)
+		}
+		key, err := ParseTokenKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token_key_env: %w", err)
+		}
+		return key, nil
+	}
+	encoded, err := readSecretFile("token_key_file", keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseTokenKey(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token_key_file: %w", err)
+	}
+	return key, nil
+}
+
+// readSecretFile reads the secret stored in path, refusing files which can be
+// accessed by group or others. option is the config option used in errors.
+func readSecretFile(option, path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", option, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("invalid %s: %s is not a regular file", option, path)
+	}
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		return "", fmt.Errorf("invalid %s: %s must not be accessible by group or others (mode %#o)", option, path, perm)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", option, err)
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("invalid %s: %s is empty", option, path)
+	}
+	return secret, nil
+}
+
+func (c *Config) GetCreateTimeout() time.Duration {
+	if c.CreateTimeout == 0 {
+		return DefaultCreateTimeout
+	}
+	return c.CreateTimeout
+}
+
+func (c *Config) GetRetryMaxAttempts() int {
+	if c.RetryMaxAttempts == 0 {
+		return DefaultRetryMaxAttempts
+	}
+	return c.RetryMaxAttempts
+}
+
+func (c *Config) GetRetryMaxDelay() time.Duration {
+	if c.RetryMaxDelay == 0 {
+		return DefaultRetryMaxDelay
+	}
+	return c.RetryMaxDelay
+}
+
+func (c *Config) GetStuckTimeout() time.Duration {
+	if c.StuckTimeout == 0 {
+		return DefaultStuckTimeout
+	}
+	return c.StuckTimeout
+}
+
+func (c *Config) GetShutdownTimeout() time.Duration {
+	if c.ShutdownTimeout == 0 {
+		return DefaultShutdownTimeout
+	}
+	return c.ShutdownTimeout
+}
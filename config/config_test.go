@@ -1,9 +1,14 @@
 package config
 
 import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -21,10 +26,271 @@ func TestNewConfig(t *testing.T) {
 			`,
 			errString: "",
 			expectedConfig: &Config{
-				Location: "location",
+				Location: Locations{"location"},
 				Token:    "token",
 			},
 		},
+		{
+			name: "wait for create",
+			content: `
+			location = "location"
+			token = "token"
+			wait_for_create = true
+			create_timeout = "10m"
+			`,
+			errString: "",
+			expectedConfig: &Config{
+				Location:      Locations{"location"},
+				Token:         "token",
+				WaitForCreate: true,
+				CreateTimeout: 10 * time.Minute,
+			},
+		},
+		{
+			name: "invalid create timeout",
+			content: `
+			location = "location"
+			token = "token"
+			create_timeout = "-1m"
+			`,
+			errString:      "invalid create_timeout",
+			expectedConfig: nil,
+		},
+		{
+			name: "retry options",
+			content: `
+			location = "location"
+			token = "token"
+			retry_max_attempts = 3
+			retry_max_delay = "1m"
+			`,
+			errString: "",
+			expectedConfig: &Config{
+				Location:         Locations{"location"},
+				Token:            "token",
+				RetryMaxAttempts: 3,
+				RetryMaxDelay:    time.Minute,
+			},
+		},
+		{
+			name: "invalid retry max attempts",
+			content: `
+			location = "location"
+			token = "token"
+			retry_max_attempts = -1
+			`,
+			errString:      "invalid retry_max_attempts",
+			expectedConfig: nil,
+		},
+		{
+			name: "invalid retry max delay",
+			content: `
+			location = "location"
+			token = "token"
+			retry_max_delay = "-1s"
+			`,
+			errString:      "invalid retry_max_delay",
+			expectedConfig: nil,
+		},
+		{
+			name: "location list",
+			content: `
+			location = ["nbg1", "fsn1"]
+			token = "token"
+			`,
+			errString: "",
+			expectedConfig: &Config{
+				Location: Locations{"nbg1", "fsn1"},
+				Token:    "token",
+			},
+		},
+		{
+			name: "invalid location",
+			content: `
+			location = 1
+			token = "token"
+			`,
+			errString:      "invalid location",
+			expectedConfig: nil,
+		},
+		{
+			name: "empty location in list",
+			content: `
+			location = ["nbg1", ""]
+			token = "token"
+			`,
+			errString:      "invalid empty location",
+			expectedConfig: nil,
+		},
+		{
+			name: "stuck timeout",
+			content: `
+			location = "location"
+			token = "token"
+			stuck_timeout = "30m"
+			`,
+			errString: "",
+			expectedConfig: &Config{
+				Location:     Locations{"location"},
+				Token:        "token",
+				StuckTimeout: 30 * time.Minute,
+			},
+		},
+		{
+			name: "invalid stuck timeout",
+			content: `
+			location = "location"
+			token = "token"
+			stuck_timeout = "-1m"
+			`,
+			errString:      "invalid stuck_timeout: -1m0s",
+			expectedConfig: nil,
+		},
+		{
+			name: "shutdown timeout",
+			content: `
+			location = "location"
+			token = "token"
+			shutdown_timeout = "3m"
+			`,
+			errString: "",
+			expectedConfig: &Config{
+				Location:        Locations{"location"},
+				Token:           "token",
+				ShutdownTimeout: 3 * time.Minute,
+			},
+		},
+		{
+			name: "invalid shutdown timeout",
+			content: `
+			location = "location"
+			token = "token"
+			shutdown_timeout = "-3m"
+			`,
+			errString:      "invalid shutdown_timeout: -3m0s",
+			expectedConfig: nil,
+		},
+		{
+			name: "defaults",
+			content: `
+			location = "location"
+			token = "token"
+
+			[defaults]
+			ssh_keys = [123456, "deploy"]
+			firewalls = ["label:env=prod"]
+			`,
+			errString: "",
+			expectedConfig: &Config{
+				Location: Locations{"location"},
+				Token:    "token",
+				Defaults: map[string]any{
+					"ssh_keys":  []any{int64(123456), "deploy"},
+					"firewalls": []any{"label:env=prod"},
+				},
+			},
+		},
+		{
+			name: "profiles",
+			content: `
+			location = "location"
+			token = "token"
+
+			[profiles.private-only]
+			disable_ipv4 = true
+			networks = [123123]
+
+			[profiles.gpu-build]
+			location = "fsn1"
+			`,
+			errString: "",
+			expectedConfig: &Config{
+				Location: Locations{"location"},
+				Token:    "token",
+				Profiles: map[string]map[string]any{
+					"private-only": {"disable_ipv4": true, "networks": []any{int64(123123)}},
+					"gpu-build":    {"location": "fsn1"},
+				},
+			},
+		},
+		{
+			name: "projects",
+			content: `
+			location = "location"
+			token = "token"
+
+			[projects.overflow]
+			token = "overflow-token"
+			location = ["fsn1", "hel1"]
+			`,
+			errString: "",
+			expectedConfig: &Config{
+				Location: Locations{"location"},
+				Token:    "token",
+				Projects: map[string]Project{
+					"overflow": {
+						Location: Locations{"fsn1", "hel1"},
+						Token:    "overflow-token",
+					},
+				},
+			},
+		},
+		{
+			name: "invalid project name",
+			content: `
+			location = "location"
+			token = "token"
+
+			[projects."over:flow"]
+			token = "overflow-token"
+			`,
+			errString:      `invalid project "over:flow": name must start and end with an alphanumeric character`,
+			expectedConfig: nil,
+		},
+		{
+			name: "project without token",
+			content: `
+			location = "location"
+			token = "token"
+
+			[projects.overflow]
+			location = "fsn1"
+			`,
+			errString:      `invalid project "overflow": missing token`,
+			expectedConfig: nil,
+		},
+		{
+			name: "labels",
+			content: `
+			location = "location"
+			token = "token"
+
+			[labels]
+			cost-centre = "cc-1234"
+			"example.com/team" = "platform"
+			`,
+			errString: "",
+			expectedConfig: &Config{
+				Location: Locations{"location"},
+				Token:    "token",
+				Labels: map[string]string{
+					"cost-centre":      "cc-1234",
+					"example.com/team": "platform",
+				},
+			},
+		},
+		{
+			name: "reserved label",
+			content: `
+			location = "location"
+			token = "token"
+
+			[labels]
+			GARM_POOL_ID = "pool"
+			`,
+			errString:      "invalid labels: label \"GARM_POOL_ID\" is reserved",
+			expectedConfig: nil,
+		},
 		{
 			name: "missing token",
 			content: `
@@ -71,3 +337,198 @@ func TestNewConfigInvalidFile(t *testing.T) {
 		assert.Nil(t, config)
 	})
 }
+
+func TestGetCreateTimeout(t *testing.T) {
+	config := &Config{}
+	assert.Equal(t, DefaultCreateTimeout, config.GetCreateTimeout())
+	config.CreateTimeout = time.Minute
+	assert.Equal(t, time.Minute, config.GetCreateTimeout())
+}
+
+func TestGetRetryOptions(t *testing.T) {
+	config := &Config{}
+	assert.Equal(t, DefaultRetryMaxAttempts, config.GetRetryMaxAttempts())
+	assert.Equal(t, DefaultRetryMaxDelay, config.GetRetryMaxDelay())
+	config.RetryMaxAttempts = 1
+	config.RetryMaxDelay = time.Second
+	assert.Equal(t, 1, config.GetRetryMaxAttempts())
+	assert.Equal(t, time.Second, config.GetRetryMaxDelay())
+}
+
+func TestGetStuckTimeout(t *testing.T) {
+	config := &Config{}
+	assert.Equal(t, DefaultStuckTimeout, config.GetStuckTimeout())
+	config.StuckTimeout = time.Hour
+	assert.Equal(t, time.Hour, config.GetStuckTimeout())
+}
+
+func TestGetShutdownTimeout(t *testing.T) {
+	config := &Config{}
+	assert.Equal(t, DefaultShutdownTimeout, config.GetShutdownTimeout())
+	config.ShutdownTimeout = time.Hour
+	assert.Equal(t, time.Hour, config.GetShutdownTimeout())
+}
+
+func TestNewConfigTokenSources(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("file-secret\n"), 0o600))
+	openTokenFile := filepath.Join(dir, "open-token")
+	assert.NoError(t, os.WriteFile(openTokenFile, []byte("open-secret"), 0o644))
+	emptyTokenFile := filepath.Join(dir, "empty-token")
+	assert.NoError(t, os.WriteFile(emptyTokenFile, []byte("\n"), 0o600))
+	t.Setenv("TEST_HCLOUD_TOKEN", "env-secret")
+
+	key := bytes.Repeat([]byte{1}, TokenKeySize)
+	encodedKey := base64.StdEncoding.EncodeToString(key)
+	keyFile := filepath.Join(dir, "key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte(encodedKey+"\n"), 0o600))
+	openKeyFile := filepath.Join(dir, "open-key")
+	assert.NoError(t, os.WriteFile(openKeyFile, []byte(encodedKey), 0o640))
+	t.Setenv("TEST_HCLOUD_TOKEN_KEY", encodedKey)
+	t.Setenv("TEST_HCLOUD_TOKEN_BAD_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, TokenKeySize)))
+	encryptedToken, err := EncryptToken("encrypted-secret", key)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		content   string
+		token     string
+		errString string
+	}{
+		{
+			name:    "token",
+			content: `token = "plain-secret"`,
+			token:   "plain-secret",
+		},
+		{
+			name:    "token from environment",
+			content: `token_env = "TEST_HCLOUD_TOKEN"`,
+			token:   "env-secret",
+		},
+		{
+			name:      "unset environment variable",
+			content:   `token_env = "TEST_HCLOUD_TOKEN_UNSET"`,
+			errString: "environment variable TEST_HCLOUD_TOKEN_UNSET from token_env is not set",
+		},
+		{
+			name:    "token from file",
+			content: fmt.Sprintf("token_file = %q", tokenFile),
+			token:   "file-secret",
+		},
+		{
+			name:      "token file accessible by others",
+			content:   fmt.Sprintf("token_file = %q", openTokenFile),
+			errString: "must not be accessible by group or others (mode 0644)",
+		},
+		{
+			name:      "empty token file",
+			content:   fmt.Sprintf("token_file = %q", emptyTokenFile),
+			errString: "is empty",
+		},
+		{
+			name:      "missing token file",
+			content:   fmt.Sprintf("token_file = %q", filepath.Join(dir, "missing")),
+			errString: "no such file or directory",
+		},
+		{
+			name:    "encrypted token with key file",
+			content: fmt.Sprintf("encrypted_token = %q\ntoken_key_file = %q", encryptedToken, keyFile),
+			token:   "encrypted-secret",
+		},
+		{
+			name:    "encrypted token with key from environment",
+			content: fmt.Sprintf("encrypted_token = %q\ntoken_key_env = \"TEST_HCLOUD_TOKEN_KEY\"", encryptedToken),
+			token:   "encrypted-secret",
+		},
+		{
+			name:      "encrypted token with wrong key",
+			content:   fmt.Sprintf("encrypted_token = %q\ntoken_key_env = \"TEST_HCLOUD_TOKEN_BAD_KEY\"", encryptedToken),
+			errString: "invalid encrypted_token: failed to decrypt token: wrong key or corrupted value",
+		},
+		{
+			name:      "encrypted token with key file accessible by others",
+			content:   fmt.Sprintf("encrypted_token = %q\ntoken_key_file = %q", encryptedToken, openKeyFile),
+			errString: "invalid token_key_file: " + openKeyFile + " must not be accessible by group or others (mode 0640)",
+		},
+		{
+			name:      "encrypted token with unset key environment variable",
+			content:   fmt.Sprintf("encrypted_token = %q\ntoken_key_env = \"TEST_HCLOUD_TOKEN_KEY_UNSET\"", encryptedToken),
+			errString: "environment variable TEST_HCLOUD_TOKEN_KEY_UNSET from token_key_env is not set",
+		},
+		{
+			name:      "encrypted token without key",
+			content:   fmt.Sprintf("encrypted_token = %q", encryptedToken),
+			errString: "encrypted_token requires one of token_key_file or token_key_env",
+		},
+		{
+			name:      "encrypted token with several keys",
+			content:   fmt.Sprintf("encrypted_token = %q\ntoken_key_file = %q\ntoken_key_env = \"TEST_HCLOUD_TOKEN_KEY\"", encryptedToken, keyFile),
+			errString: "token_key_file and token_key_env are mutually exclusive",
+		},
+		{
+			name:      "key without encrypted token",
+			content:   fmt.Sprintf("token = \"plain-secret\"\ntoken_key_file = %q", keyFile),
+			errString: "token_key_file and token_key_env can only be set with encrypted_token",
+		},
+		{
+			name:      "several token sources",
+			content:   "token = \"plain-secret\"\ntoken_env = \"TEST_HCLOUD_TOKEN\"",
+			errString: "token, token_env, token_file and encrypted_token are mutually exclusive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfgFile := filepath.Join(t.TempDir(), "config.toml")
+			assert.NoError(t, os.WriteFile(cfgFile, []byte("location = \"nbg1\"\n"+tt.content), 0o600))
+			config, err := NewConfig(cfgFile)
+			if tt.errString != "" {
+				assert.ErrorContains(t, err, tt.errString)
+				for _, secret := range []string{"plain-secret", "env-secret", "file-secret", "open-secret", "encrypted-secret", encodedKey} {
+					assert.NotContains(t, err.Error(), secret)
+				}
+				assert.Nil(t, config)
+				return
+			}
+			assert.NoError(t, err)
+			token, err := config.GetToken()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.token, token)
+		})
+	}
+}
+
+func TestProjectConfig(t *testing.T) {
+	cfg := &Config{
+		Location:      Locations{"nbg1"},
+		Token:         "token",
+		WaitForCreate: true,
+		Projects: map[string]Project{
+			"overflow": {Token: "overflow-token", Location: Locations{"fsn1"}},
+			"other":    {TokenEnv: "OTHER_TOKEN"},
+		},
+	}
+
+	projectCfg, err := cfg.ProjectConfig("")
+	assert.NoError(t, err)
+	assert.Same(t, cfg, projectCfg)
+
+	projectCfg, err = cfg.ProjectConfig("overflow")
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{
+		Location:      Locations{"fsn1"},
+		Token:         "overflow-token",
+		WaitForCreate: true,
+	}, projectCfg)
+
+	projectCfg, err = cfg.ProjectConfig("other")
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{
+		Location:      Locations{"nbg1"},
+		TokenEnv:      "OTHER_TOKEN",
+		WaitForCreate: true,
+	}, projectCfg)
+
+	_, err = cfg.ProjectConfig("missing")
+	assert.EqualError(t, err, `unknown project "missing"`)
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// TokenKeySize is the size in bytes of the AES-256 key used to encrypt the
+// token. Keys are stored base64 encoded.
+const TokenKeySize = 32
+
+// ParseTokenKey decodes a base64 encoded token encryption key.
+func ParseTokenKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("key is not valid base64")
+	}
+	if len(key) != TokenKeySize {
+		return nil, fmt.Errorf("key must be %d bytes long, got %d", TokenKeySize, len(key))
+	}
+	return key, nil
+}
+
+// EncryptToken encrypts token with AES-256-GCM. The result holds the random
+// nonce followed by the sealed token, base64 encoded.
+func EncryptToken(token string, key []byte) (string, error) {
+	aead, err := newTokenCipher(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(token), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptToken reverses EncryptToken. It fails when the blob was tampered with
+// or encrypted with another key.
+func DecryptToken(encrypted string, key []byte) (string, error) {
+	aead, err := newTokenCipher(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encrypted))
+	if err != nil {
+		return "", fmt.Errorf("encrypted token is not valid base64")
+	}
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return "", fmt.Errorf("encrypted token is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	token, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt token: wrong key or corrupted value")
+	}
+	return string(token), nil
+}
+
+func newTokenCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != TokenKeySize {
+		return nil, fmt.Errorf("key must be %d bytes long, got %d", TokenKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return aead, nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseTokenKey(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, TokenKeySize)
+
+	parsed, err := ParseTokenKey(base64.StdEncoding.EncodeToString(key) + "\n")
+	assert.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	_, err = ParseTokenKey("not base64!")
+	assert.EqualError(t, err, "key is not valid base64")
+
+	_, err = ParseTokenKey(base64.StdEncoding.EncodeToString(key[:16]))
+	assert.EqualError(t, err, "key must be 32 bytes long, got 16")
+}
+
+func TestEncryptToken(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, TokenKeySize)
+	otherKey := bytes.Repeat([]byte{2}, TokenKeySize)
+
+	encrypted, err := EncryptToken("secret-token", key)
+	assert.NoError(t, err)
+	assert.NotContains(t, encrypted, "secret-token")
+
+	again, err := EncryptToken("secret-token", key)
+	assert.NoError(t, err)
+	assert.NotEqual(t, encrypted, again)
+
+	token, err := DecryptToken(encrypted, key)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret-token", token)
+
+	_, err = DecryptToken(encrypted, otherKey)
+	assert.EqualError(t, err, "failed to decrypt token: wrong key or corrupted value")
+
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	assert.NoError(t, err)
+	sealed[len(sealed)-1] ^= 1
+	_, err = DecryptToken(base64.StdEncoding.EncodeToString(sealed), key)
+	assert.EqualError(t, err, "failed to decrypt token: wrong key or corrupted value")
+
+	_, err = DecryptToken("c2hvcnQ=", key)
+	assert.EqualError(t, err, "encrypted token is too short")
+
+	_, err = EncryptToken("secret-token", key[:16])
+	assert.EqualError(t, err, "key must be 32 bytes long, got 16")
+}
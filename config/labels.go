@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ReservedLabels are set by the provider on every server and can't be
+// overridden by custom labels.
+var ReservedLabels = []string{
+	"Name",
+	"GARM_POOL_ID",
+	"OSType",
+	"OSArch",
+	"GARM_CONTROLLER_ID",
+	"Location",
+}
+
+const (
+	maxLabelPrefixLength = 253
+	maxLabelNameLength   = 63
+	hetznerLabelPrefix   = "hetzner.cloud/"
+)
+
+var (
+	labelNameRegexp   = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._-]*[a-zA-Z0-9])?$`)
+	labelPrefixRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+)
+
+// ValidateLabels checks custom labels against the Hetzner label syntax and
+// refuses the labels reserved by the provider.
+func ValidateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if err := validateLabelKey(key); err != nil {
+			return err
+		}
+		if err := validateLabelValue(value); err != nil {
+			return fmt.Errorf("invalid value for label %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func validateLabelKey(key string) error {
+	for _, reserved := range ReservedLabels {
+		if key == reserved {
+			return fmt.Errorf("label %q is reserved", key)
+		}
+	}
+	if strings.HasPrefix(key, hetznerLabelPrefix) {
+		return fmt.Errorf("label %q uses the reserved %q prefix", key, hetznerLabelPrefix)
+	}
+
+	name := key
+	if prefix, rest, ok := strings.Cut(key, "/"); ok {
+		if len(prefix) > maxLabelPrefixLength || !labelPrefixRegexp.MatchString(prefix) {
+			return fmt.Errorf("invalid label key %q: prefix must be a DNS subdomain", key)
+		}
+		name = rest
+	}
+	if len(name) > maxLabelNameLength || !labelNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid label key %q", key)
+	}
+	return nil
+}
+
+func validateLabelValue(value string) error {
+	if value == "" {
+		return nil
+	}
+	if len(value) > maxLabelNameLength {
+		return fmt.Errorf("value must be at most %d characters", maxLabelNameLength)
+	}
+	if !labelNameRegexp.MatchString(value) {
+		return fmt.Errorf("value must start and end with an alphanumeric character and only contain alphanumerics, '-', '_' or '.'")
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestValidateLabels(t *testing.T) {
+	tests := []struct {
+		name      string
+		labels    map[string]string
+		errString string
+	}{
+		{
+			name: "valid labels",
+			labels: map[string]string{
+				"team":                  "platform",
+				"cost_centre":           "CC.1234",
+				"example.com/owner":     "ci-runners",
+				"empty":                 "",
+				strings.Repeat("a", 63): strings.Repeat("b", 63),
+			},
+		},
+		{
+			name:   "nil labels",
+			labels: nil,
+		},
+		{
+			name:      "reserved label",
+			labels:    map[string]string{"Name": "runner"},
+			errString: "label \"Name\" is reserved",
+		},
+		{
+			name:      "reserved prefix",
+			labels:    map[string]string{"hetzner.cloud/team": "platform"},
+			errString: "label \"hetzner.cloud/team\" uses the reserved \"hetzner.cloud/\" prefix",
+		},
+		{
+			name:      "invalid key",
+			labels:    map[string]string{"-team": "platform"},
+			errString: "invalid label key \"-team\"",
+		},
+		{
+			name:      "empty key",
+			labels:    map[string]string{"": "platform"},
+			errString: "invalid label key \"\"",
+		},
+		{
+			name:      "key too long",
+			labels:    map[string]string{strings.Repeat("a", 64): "platform"},
+			errString: "invalid label key",
+		},
+		{
+			name:      "invalid prefix",
+			labels:    map[string]string{"Example.com/team": "platform"},
+			errString: "invalid label key \"Example.com/team\": prefix must be a DNS subdomain",
+		},
+		{
+			name:      "invalid value",
+			labels:    map[string]string{"team": "platform team"},
+			errString: "invalid value for label \"team\": value must start and end with an alphanumeric character",
+		},
+		{
+			name:      "value too long",
+			labels:    map[string]string{"team": strings.Repeat("a", 64)},
+			errString: "invalid value for label \"team\": value must be at most 63 characters",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLabels(tt.labels)
+			if tt.errString == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.errString)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/imtf-group/garm-provider-hetzner/config"
+)
+
+const encryptTokenCommand = "encrypt-token"
+
+// encryptToken reads a plaintext token from stdin and writes the value to use
+// as encrypted_token in the provider config to stdout.
+func encryptToken(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet(encryptTokenCommand, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s (-key-file PATH | -key-env NAME) < token\n", encryptTokenCommand) //nolint:errcheck
+		flags.PrintDefaults()
+	}
+	keyFile := flags.String("key-file", "", "file holding the base64 encoded 32 bytes key")
+	keyEnv := flags.String("key-env", "", "environment variable holding the base64 encoded 32 bytes key")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s (the token is read from stdin)", strings.Join(flags.Args(), " "))
+	}
+	if (*keyFile == "") == (*keyEnv == "") {
+		return fmt.Errorf("exactly one of -key-file or -key-env must be set")
+	}
+
+	key, err := config.LoadTokenKey(*keyFile, *keyEnv)
+	if err != nil {
+		return err
+	}
+	token, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read token: %w", err)
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return fmt.Errorf("missing token on stdin")
+	}
+	encrypted, err := config.EncryptToken(token, key)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, encrypted)
+	return err
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	garmErrors "github.com/cloudbase/garm-provider-common/errors"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// Errors returned by HcloudClient can be checked with errors.Is against the
+// following sentinels. ErrNotFound and ErrAlreadyExists also match the
+// corresponding garm-provider-common errors.
+var (
+	ErrNotFound            = garmErrors.NewNotFoundError("not found")
+	ErrAlreadyExists       = garmErrors.NewDuplicateUserError("already exists")
+	ErrQuotaExceeded       = errors.New("quota exceeded")
+	ErrRateLimited         = errors.New("rate limited")
+	ErrResourceUnavailable = errors.New("resource unavailable")
+)
+
+// InvalidStateError is returned when a server can't be started or stopped
+// in its current status.
+type InvalidStateError struct {
+	Instance  string
+	Operation string
+	Status    hcloud.ServerStatus
+}
+
+func (e *InvalidStateError) Error() string {
+	return fmt.Sprintf("instance %s cannot be %s in %s state", e.Instance, e.Operation, e.Status)
+}
+
+// apiError keeps the original Hetzner API error while matching the sentinel
+// error of its error code.
+type apiError struct {
+	kind error
+	err  error
+}
+
+func (e *apiError) Error() string {
+	return e.err.Error()
+}
+
+func (e *apiError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// wrapAPIError wraps err with the sentinel error matching its Hetzner error
+// code. Other errors are returned unchanged.
+func wrapAPIError(err error) error {
+	var hcloudErr hcloud.Error
+	if !errors.As(err, &hcloudErr) {
+		return err
+	}
+	var kind error
+	switch hcloudErr.Code { //nolint:exhaustive
+	case hcloud.ErrorCodeNotFound:
+		kind = ErrNotFound
+	case hcloud.ErrorCodeUniquenessError:
+		kind = ErrAlreadyExists
+	case hcloud.ErrorCodeResourceLimitExceeded:
+		kind = ErrQuotaExceeded
+	case hcloud.ErrorCodeRateLimitExceeded:
+		kind = ErrRateLimited
+	case hcloud.ErrorCodeResourceUnavailable,
+		hcloud.ErrorCodePlacementError,
+		errorCodeUnsupportedLocationForServerType:
+		kind = ErrResourceUnavailable
+	default:
+		return err
+	}
+	return &apiError{kind: kind, err: err}
+}
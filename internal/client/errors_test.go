@@ -0,0 +1,75 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	garmErrors "github.com/cloudbase/garm-provider-common/errors"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWrapAPIError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{
+			name:     "not found",
+			err:      hcloud.Error{Code: hcloud.ErrorCodeNotFound, Message: "server not found"},
+			expected: ErrNotFound,
+		},
+		{
+			name:     "uniqueness error",
+			err:      hcloud.Error{Code: hcloud.ErrorCodeUniquenessError, Message: "name is already used"},
+			expected: ErrAlreadyExists,
+		},
+		{
+			name:     "resource limit exceeded",
+			err:      hcloud.Error{Code: hcloud.ErrorCodeResourceLimitExceeded, Message: "server limit exceeded"},
+			expected: ErrQuotaExceeded,
+		},
+		{
+			name:     "rate limit exceeded",
+			err:      hcloud.Error{Code: hcloud.ErrorCodeRateLimitExceeded, Message: "limit reached"},
+			expected: ErrRateLimited,
+		},
+		{
+			name:     "resource unavailable",
+			err:      hcloud.Error{Code: hcloud.ErrorCodeResourceUnavailable, Message: "cx22 is unavailable"},
+			expected: ErrResourceUnavailable,
+		},
+		{
+			name:     "unsupported location for server type",
+			err:      hcloud.Error{Code: errorCodeUnsupportedLocationForServerType, Message: "unsupported location"},
+			expected: ErrResourceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fmt.Errorf("error while starting: %w", wrapAPIError(tt.err))
+			assert.ErrorIs(t, err, tt.expected)
+			assert.True(t, hcloud.IsError(err, tt.err.(hcloud.Error).Code))
+			assert.Equal(t, "error while starting: "+tt.err.Error(), err.Error())
+		})
+	}
+}
+
+func TestWrapAPIErrorUnchanged(t *testing.T) {
+	err := fmt.Errorf("connection reset")
+	assert.Equal(t, err, wrapAPIError(err))
+
+	err = hcloud.Error{Code: hcloud.ErrorCodeInvalidInput, Message: "invalid input"}
+	assert.Equal(t, err, wrapAPIError(err))
+	assert.False(t, errors.Is(wrapAPIError(err), ErrNotFound))
+}
+
+func TestErrorsMatchGarmErrors(t *testing.T) {
+	err := wrapAPIError(hcloud.Error{Code: hcloud.ErrorCodeNotFound})
+	assert.ErrorIs(t, err, garmErrors.ErrNotFound)
+
+	err = wrapAPIError(hcloud.Error{Code: hcloud.ErrorCodeUniquenessError})
+	assert.ErrorIs(t, err, garmErrors.ErrDuplicateEntity)
+}
@@ -2,14 +2,39 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	garmErrors "github.com/cloudbase/garm-provider-common/errors"
 	"github.com/cloudbase/garm-provider-common/params"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	"github.com/imtf-group/garm-provider-hetzner/config"
 	"github.com/imtf-group/garm-provider-hetzner/internal/spec"
+	"log/slog"
+	"maps"
+	"net"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
+const (
+	maxParallelDeletions = 10
+	rollbackTimeout      = time.Minute
+)
+
+var shutdownPollInterval = 5 * time.Second
+
+const (
+	imageIDPrefix    = "id:"
+	imageNamePrefix  = "name:"
+	imageLabelPrefix = "label:"
+)
+
+const errorCodeUnsupportedLocationForServerType hcloud.ErrorCode = "unsupported_location_for_server_type"
+
 type HcloudClient struct {
 	cfg *config.Config
 	api ClientInterface
@@ -23,40 +48,92 @@ func DeserializeInstance(instance *hcloud.Server) params.ProviderInstance {
 	for key, value := range instance.Labels {
 		switch key {
 		case "Name":
-			providerInstance.Name = value
+			providerInstance.Name = decodeLabelValue(value)
 		case "OSType":
-			providerInstance.OSType = params.OSType(value)
+			providerInstance.OSType = params.OSType(decodeLabelValue(value))
 		case "OSArch":
-			providerInstance.OSArch = params.OSArch(value)
+			providerInstance.OSArch = params.OSArch(decodeLabelValue(value))
 		}
 	}
 
-	switch instance.Status {
-	case hcloud.ServerStatusInitializing,
-		hcloud.ServerStatusRunning,
-		hcloud.ServerStatusStarting,
+	if ip := instance.PublicNet.IPv4.IP; ip != nil {
+		providerInstance.Addresses = append(providerInstance.Addresses, params.Address{
+			Address: ip.String(),
+			Type:    params.PublicAddress,
+		})
+	}
+	if ip := firstIPv6Address(instance.PublicNet.IPv6.IP); ip != nil {
+		providerInstance.Addresses = append(providerInstance.Addresses, params.Address{
+			Address: ip.String(),
+			Type:    params.PublicAddress,
+		})
+	}
+	for _, privateNet := range instance.PrivateNet {
+		if privateNet.IP == nil {
+			continue
+		}
+		providerInstance.Addresses = append(providerInstance.Addresses, params.Address{
+			Address: privateNet.IP.String(),
+			Type:    params.PrivateAddress,
+		})
+	}
+
+	providerInstance.Status = instanceStatus(instance.Status)
+
+	return providerInstance
+}
+
+// instanceStatus maps a Hetzner server status to a GARM instance status.
+// Servers changing state are reported as unknown, so that GARM neither starts
+// nor stops them while the transition is in progress.
+func instanceStatus(status hcloud.ServerStatus) params.InstanceStatus {
+	switch status {
+	case hcloud.ServerStatusInitializing:
+		return params.InstanceCreating
+	case hcloud.ServerStatusRunning:
+		return params.InstanceRunning
+	case hcloud.ServerStatusOff:
+		return params.InstanceStopped
+	case hcloud.ServerStatusDeleting:
+		return params.InstanceDeleting
+	case hcloud.ServerStatusStarting,
+		hcloud.ServerStatusStopping,
 		hcloud.ServerStatusMigrating,
 		hcloud.ServerStatusRebuilding,
-		hcloud.ServerStatusStopping:
-
-		providerInstance.Status = params.InstanceRunning
-	case hcloud.ServerStatusOff,
-		hcloud.ServerStatusDeleting:
+		hcloud.ServerStatusUnknown:
 
-		providerInstance.Status = params.InstanceStopped
+		return params.InstanceStatusUnknown
 	default:
-		providerInstance.Status = params.InstanceStatusUnknown
+		return params.InstanceStatusUnknown
 	}
+}
 
-	return providerInstance
+// firstIPv6Address returns the first address of the /64 network assigned to
+// a server, which is the address the server is configured with.
+func firstIPv6Address(network net.IP) net.IP {
+	ip := network.Mask(net.CIDRMask(64, 128))
+	if ip == nil {
+		return nil
+	}
+	ip[len(ip)-1] = 1
+	return ip
 }
 
 func NewClient(ctx context.Context, cfg *config.Config) (*HcloudClient, error) {
-	client := hcloud.NewClient(hcloud.WithToken(cfg.Token))
+	token, err := cfg.GetToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// Retries are handled by RetryAPI, disable the ones of the hcloud client.
+	client := hcloud.NewClient(
+		hcloud.WithToken(token),
+		hcloud.WithRetryOpts(hcloud.RetryOpts{MaxRetries: 0}),
+	)
 
 	hcloudClient := &HcloudClient{
 		cfg: cfg,
-		api: &HCloudAPI{client: client},
+		api: NewRetryAPI(&HCloudAPI{client: client}, cfg.GetRetryMaxAttempts(), cfg.GetRetryMaxDelay()),
 	}
 
 	return hcloudClient, nil
@@ -78,50 +155,80 @@ func (c *HcloudClient) SetApi(api ClientInterface) {
 	c.api = api
 }
 
-func (c *HcloudClient) CreateInstance(ctx context.Context, spec *spec.RunnerSpec) (string, error) {
+func (c *HcloudClient) CreateInstance(ctx context.Context, spec *spec.RunnerSpec) (*hcloud.Server, error) {
 	if spec == nil {
-		return "", fmt.Errorf("invalid nil runner spec")
+		return nil, fmt.Errorf("invalid nil runner spec")
+	}
+	if len(spec.Locations) == 0 {
+		return nil, fmt.Errorf("missing location")
 	}
 
-	udata, err := spec.ComposeUserData()
+	existing, err := c.findExistingInstance(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		slog.InfoContext(ctx, "adopting existing instance", "server_id", existing.ID, "name", existing.Name)
+		return existing, nil
+	}
+
+	arch, err := architecture(spec.BootstrapParams.OSArch)
 	if err != nil {
-		return "", fmt.Errorf("failed to compose user data: %w", err)
+		return nil, err
 	}
 
-	serverType := &hcloud.ServerType{Name: spec.BootstrapParams.Flavor}
-	location := &hcloud.Location{Name: spec.Location}
-	image := &hcloud.Image{Name: spec.BootstrapParams.Image}
+	serverTypes, err := c.serverTypes(ctx, spec, arch)
+	if err != nil {
+		return nil, err
+	}
 
-	var sshKeys []*hcloud.SSHKey
-	var networks []*hcloud.Network
-	var firewalls []*hcloud.ServerCreateFirewall
-	var placementGroup *hcloud.PlacementGroup
+	image, err := c.image(ctx, spec, arch)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, sshKey := range spec.SSHKeys {
-		sshKeys = append(sshKeys, &hcloud.SSHKey{ID: sshKey})
+	udata, err := spec.ComposeUserData()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compose user data: %w", err)
+	}
+
+	sshKeys, err := resolveRefs(ctx, "SSH key", spec.SSHKeys,
+		func(id int64) *hcloud.SSHKey { return &hcloud.SSHKey{ID: id} },
+		c.api.GetSSHKey, c.api.GetSSHKeysByLabel)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, network := range spec.Networks {
-		networks = append(networks, &hcloud.Network{ID: network})
+	networks, err := resolveRefs(ctx, "network", spec.Networks,
+		func(id int64) *hcloud.Network { return &hcloud.Network{ID: id} },
+		c.api.GetNetwork, c.api.GetNetworksByLabel)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, firewall := range spec.Firewalls {
+	resolvedFirewalls, err := resolveRefs(ctx, "firewall", spec.Firewalls,
+		func(id int64) *hcloud.Firewall { return &hcloud.Firewall{ID: id} },
+		c.api.GetFirewall, c.api.GetFirewallsByLabel)
+	if err != nil {
+		return nil, err
+	}
+	var firewalls []*hcloud.ServerCreateFirewall
+	for _, firewall := range resolvedFirewalls {
 		firewalls = append(firewalls, &hcloud.ServerCreateFirewall{
-			Firewall: hcloud.Firewall{ID: firewall},
+			Firewall: hcloud.Firewall{ID: firewall.ID},
 		})
 	}
 
-	if spec.PlacementGroup != 0 {
-		placementGroup = &hcloud.PlacementGroup{ID: spec.PlacementGroup}
+	placementGroup, err := c.placementGroup(ctx, spec.PlacementGroup)
+	if err != nil {
+		return nil, err
 	}
 
-	result, _, err := c.api.CreateServer(ctx, hcloud.ServerCreateOpts{
+	opts := hcloud.ServerCreateOpts{
 		UserData:         udata,
 		Name:             spec.BootstrapParams.Name,
 		StartAfterCreate: hcloud.Ptr(true),
-		ServerType:       serverType,
 		Image:            image,
-		Location:         location,
 		SSHKeys:          sshKeys,
 		Networks:         networks,
 		PublicNet: &hcloud.ServerCreatePublicNet{
@@ -130,19 +237,277 @@ func (c *HcloudClient) CreateInstance(ctx context.Context, spec *spec.RunnerSpec
 		},
 		Firewalls:      firewalls,
 		PlacementGroup: placementGroup,
-		Labels: map[string]string{
-			"Name":               spec.BootstrapParams.Name,
-			"GARM_POOL_ID":       spec.BootstrapParams.PoolID,
-			"OSType":             string(spec.BootstrapParams.OSType),
-			"OSArch":             string(spec.BootstrapParams.OSArch),
-			"GARM_CONTROLLER_ID": spec.ControllerID,
-		},
+	}
+	labels, err := encodeLabels(map[string]string{
+		"Name":               spec.BootstrapParams.Name,
+		"GARM_POOL_ID":       spec.BootstrapParams.PoolID,
+		"OSType":             string(spec.BootstrapParams.OSType),
+		"OSArch":             string(spec.BootstrapParams.OSArch),
+		"GARM_CONTROLLER_ID": spec.ControllerID,
 	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create instance: %w", err)
+	}
+	for key, value := range spec.Labels {
+		if _, ok := labels[key]; !ok {
+			labels[key] = value
+		}
+	}
+
+	var result hcloud.ServerCreateResult
+create:
+	for _, serverType := range serverTypes {
+		opts.ServerType = &hcloud.ServerType{Name: serverType}
+		for _, location := range spec.Locations {
+			opts.Location = &hcloud.Location{Name: location}
+			opts.Labels = maps.Clone(labels)
+			opts.Labels["Location"] = location
+			result, _, err = c.api.CreateServer(ctx, opts)
+			switch {
+			case err == nil:
+				break create
+			case isUnavailableError(err):
+				slog.WarnContext(ctx, "server type unavailable in location", "server_type", serverType, "location", location, "error", err)
+			case hcloud.IsError(err, hcloud.ErrorCodeInvalidServerType):
+				slog.WarnContext(ctx, "server type cannot be used", "server_type", serverType, "error", err)
+				continue create
+			default:
+				break create
+			}
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create instance: %w", wrapAPIError(err))
+	}
+
+	if result.Server == nil {
+		return nil, fmt.Errorf("failed to create instance: no server returned")
+	}
+
+	if c.cfg != nil && c.cfg.WaitForCreate {
+		server, err := c.waitForCreate(ctx, result)
+		if err != nil {
+			return nil, c.rollbackCreate(ctx, result.Server, err)
+		}
+		return server, nil
+	}
+	return result.Server, nil
+}
+
+// serverTypes returns the pool flavor followed by the fallback server types,
+// skipping the ones which are not found or no longer available in any of the
+// locations of the spec. Every server type found must match the pool
+// architecture.
+func (c *HcloudClient) serverTypes(ctx context.Context, spec *spec.RunnerSpec, arch hcloud.Architecture) ([]string, error) {
+	candidates := append([]string{spec.BootstrapParams.Flavor}, spec.ServerTypeFallbacks...)
+	var serverTypes []string
+	for _, name := range candidates {
+		serverType, _, err := c.api.GetServerType(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("error while retrieving server type %q: %w", name, wrapAPIError(err))
+		}
+		if serverType == nil {
+			slog.WarnContext(ctx, "server type not found, skipping it", "server_type", name)
+			continue
+		}
+		if serverType.Architecture != arch {
+			return nil, fmt.Errorf("server type %q has %s architecture, pool requires %s", name, serverType.Architecture, arch)
+		}
+		if !serverTypeAvailable(serverType, spec.Locations, time.Now()) {
+			slog.WarnContext(ctx, "server type is no longer available, skipping it", "server_type", name)
+			continue
+		}
+		serverTypes = append(serverTypes, name)
+	}
+	if len(serverTypes) == 0 {
+		return nil, garmErrors.NewNotFoundError("no available server type among %s", strings.Join(candidates, ", "))
+	}
+	return serverTypes, nil
+}
+
+// serverTypeAvailable reports whether serverType is not past its
+// unavailability date in at least one of locations. Server types without
+// per location information fall back to their global deprecation.
+func serverTypeAvailable(serverType *hcloud.ServerType, locations []string, now time.Time) bool {
+	if len(serverType.Locations) == 0 {
+		deprecation := serverType.DeprecatableResource //nolint:staticcheck
+		return !deprecation.IsDeprecated() || now.Before(deprecation.UnavailableAfter())
+	}
+	for _, serverTypeLocation := range serverType.Locations {
+		if serverTypeLocation.Location == nil || !slices.Contains(locations, serverTypeLocation.Location.Name) {
+			continue
+		}
+		if !serverTypeLocation.IsDeprecated() || now.Before(serverTypeLocation.UnavailableAfter()) {
+			return true
+		}
+	}
+	return false
+}
+
+// image resolves the pool image. It accepts "id:<id>", "name:<name>" or
+// "label:<selector>" references, plain values being looked up by ID or name.
+// A label selector picks the newest snapshot matching the pool architecture.
+func (c *HcloudClient) image(ctx context.Context, spec *spec.RunnerSpec, arch hcloud.Architecture) (*hcloud.Image, error) {
+	ref := spec.BootstrapParams.Image
+	if selector, ok := strings.CutPrefix(ref, imageLabelPrefix); ok {
+		images, err := c.api.GetImagesByLabel(ctx, selector, arch)
+		if err != nil {
+			return nil, fmt.Errorf("error while retrieving images matching %q: %w", selector, wrapAPIError(err))
+		}
+		var newest *hcloud.Image
+		for _, image := range images {
+			if image.Architecture != arch {
+				continue
+			}
+			if newest == nil || image.Created.After(newest.Created) {
+				newest = image
+			}
+		}
+		if newest == nil {
+			return nil, garmErrors.NewNotFoundError("no %s image matches label selector %q", arch, selector)
+		}
+		return newest, nil
+	}
+
+	var (
+		image *hcloud.Image
+		err   error
+	)
+	if idRef, ok := strings.CutPrefix(ref, imageIDPrefix); ok {
+		id, parseErr := strconv.ParseInt(idRef, 10, 64)
+		if parseErr != nil {
+			return nil, fmt.Errorf("invalid image ID %q", idRef)
+		}
+		image, _, err = c.api.GetImageByID(ctx, id)
+	} else if name, ok := strings.CutPrefix(ref, imageNamePrefix); ok {
+		image, _, err = c.api.GetImageByName(ctx, name, arch)
+	} else {
+		image, _, err = c.api.GetImage(ctx, ref, arch)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error while retrieving image %q: %w", ref, wrapAPIError(err))
+	}
+	if image == nil {
+		return nil, garmErrors.NewNotFoundError("image %q not found for %s architecture", ref, arch)
+	}
+	if image.Architecture != arch {
+		return nil, fmt.Errorf("image %q has %s architecture, pool requires %s", ref, image.Architecture, arch)
+	}
+	return image, nil
+}
+
+func (c *HcloudClient) placementGroup(ctx context.Context, ref spec.ResourceRef) (*hcloud.PlacementGroup, error) {
+	if ref == "" {
+		return nil, nil
+	}
+	placementGroups, err := resolveRefs(ctx, "placement group", []spec.ResourceRef{ref},
+		func(id int64) *hcloud.PlacementGroup { return &hcloud.PlacementGroup{ID: id} },
+		c.api.GetPlacementGroup, c.api.GetPlacementGroupsByLabel)
+	if err != nil {
+		return nil, err
+	}
+	if len(placementGroups) != 1 {
+		return nil, fmt.Errorf("placement group %q matches %d placement groups", ref, len(placementGroups))
+	}
+	return placementGroups[0], nil
+}
+
+// resolveRefs resolves resource references to Hetzner resources. IDs are used
+// as is, names and label selectors are looked up through the API.
+func resolveRefs[T comparable](
+	ctx context.Context,
+	kind string,
+	refs []spec.ResourceRef,
+	fromID func(id int64) T,
+	get func(ctx context.Context, idOrName string) (T, *hcloud.Response, error),
+	list func(ctx context.Context, labelSelector string) ([]T, error),
+) ([]T, error) {
+	var zero T
+	var resolved []T
+	for _, ref := range refs {
+		if id, ok := ref.ID(); ok {
+			resolved = append(resolved, fromID(id))
+			continue
+		}
+		if selector, ok := ref.LabelSelector(); ok {
+			items, err := list(ctx, selector)
+			if err != nil {
+				return nil, fmt.Errorf("error while retrieving %ss matching %q: %w", kind, selector, wrapAPIError(err))
+			}
+			if len(items) == 0 {
+				return nil, garmErrors.NewNotFoundError("no %s matches label selector %q", kind, selector)
+			}
+			resolved = append(resolved, items...)
+			continue
+		}
+		item, _, err := get(ctx, string(ref))
+		if err != nil {
+			return nil, fmt.Errorf("error while retrieving %s %q: %w", kind, ref, wrapAPIError(err))
+		}
+		if item == zero {
+			return nil, garmErrors.NewNotFoundError("%s %q not found", kind, ref)
+		}
+		resolved = append(resolved, item)
+	}
+	return resolved, nil
+}
+
+func architecture(osArch params.OSArch) (hcloud.Architecture, error) {
+	switch osArch {
+	case params.Amd64:
+		return hcloud.ArchitectureX86, nil
+	case params.Arm64:
+		return hcloud.ArchitectureARM, nil
+	}
+	return "", fmt.Errorf("unsupported architecture: %q", osArch)
+}
+
+func isUnavailableError(err error) bool {
+	return hcloud.IsError(err, hcloud.ErrorCodeResourceUnavailable, errorCodeUnsupportedLocationForServerType)
+}
 
+func (c *HcloudClient) findExistingInstance(ctx context.Context, spec *spec.RunnerSpec) (*hcloud.Server, error) {
+	servers, err := c.GetInstancesByLabels(ctx, map[string]string{
+		"GARM_POOL_ID":       spec.BootstrapParams.PoolID,
+		"GARM_CONTROLLER_ID": spec.ControllerID,
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to create instance: %w", err)
+		return nil, err
+	}
+	for _, server := range servers {
+		if server.Name == spec.BootstrapParams.Name {
+			return server, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *HcloudClient) rollbackCreate(ctx context.Context, server *hcloud.Server, cause error) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+	if _, err := c.api.DeleteServer(ctx, server); err != nil {
+		slog.ErrorContext(ctx, "failed to roll back instance", "server_id", server.ID, "error", err)
+		return errors.Join(cause, fmt.Errorf("failed to roll back instance: %w (ID: %d)", wrapAPIError(err), server.ID))
 	}
-	return strconv.FormatInt(result.Server.ID, 10), nil
+	slog.InfoContext(ctx, "rolled back instance", "server_id", server.ID)
+	return cause
+}
+
+func (c *HcloudClient) waitForCreate(ctx context.Context, result hcloud.ServerCreateResult) (*hcloud.Server, error) {
+	var actions []*hcloud.Action
+	if result.Action != nil {
+		actions = append(actions, result.Action)
+	}
+	actions = append(actions, result.NextActions...)
+
+	waitCtx, cancel := context.WithTimeout(ctx, c.cfg.GetCreateTimeout())
+	defer cancel()
+	if err := c.api.WaitForActions(waitCtx, actions...); err != nil {
+		return nil, fmt.Errorf("error while waiting for creation: %w (ID: %d)", wrapAPIError(err), result.Server.ID)
+	}
+
+	return c.GetInstance(ctx, strconv.FormatInt(result.Server.ID, 10), false)
 }
 
 func (c *HcloudClient) DeleteInstance(ctx context.Context, instance string) error {
@@ -153,57 +518,203 @@ func (c *HcloudClient) DeleteInstance(ctx context.Context, instance string) erro
 	if server != nil {
 		_, err = c.api.DeleteServer(ctx, server)
 		if err != nil {
-			return fmt.Errorf("error during deletion: %v (ID: %d)", err, server.ID)
+			return fmt.Errorf("error during deletion: %w (ID: %d)", wrapAPIError(err), server.ID)
 		}
 	}
 	return nil
 }
 
+func (c *HcloudClient) RemoveAllInstances(ctx context.Context, controllerID string) error {
+	if controllerID == "" {
+		return fmt.Errorf("missing controller ID")
+	}
+	servers, err := c.GetInstancesByLabels(ctx, map[string]string{
+		"GARM_CONTROLLER_ID": controllerID,
+	})
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	sem := make(chan struct{}, maxParallelDeletions)
+	for _, server := range servers {
+		if decodeLabelValue(server.Labels["GARM_CONTROLLER_ID"]) != controllerID {
+			continue
+		}
+		wg.Add(1)
+		go func(server *hcloud.Server) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if _, err := c.api.DeleteServer(ctx, server); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("error during deletion: %w (ID: %d)", wrapAPIError(err), server.ID))
+				mu.Unlock()
+			}
+		}(server)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
 func (c *HcloudClient) GetInstance(ctx context.Context, instance string, ignoreNotFound bool) (*hcloud.Server, error) {
 	server, _, err := c.api.GetServer(ctx, instance)
 	if err != nil {
-		return nil, fmt.Errorf("error while retrieving the serverID: %v", err)
+		return nil, fmt.Errorf("error while retrieving the serverID: %w", wrapAPIError(err))
 	}
 	if server == nil && !ignoreNotFound {
-		return nil, fmt.Errorf("server with ID %q not found", instance)
+		return nil, garmErrors.NewNotFoundError("server with ID %q not found", instance)
 	}
 	return server, nil
 }
 
-func (c *HcloudClient) GetAllInstances(ctx context.Context) ([]*hcloud.Server, error) {
-	servers, err := c.api.GetAllServers(ctx)
+// CheckInstanceFault sets the instance status to error when the latest action
+// of the server failed, or when the server is stuck in a transitional state
+// for longer than the configured stuck timeout.
+func (c *HcloudClient) CheckInstanceFault(ctx context.Context, server *hcloud.Server, instance *params.ProviderInstance) {
+	// Only transitional states can hide a failed or stuck action, which saves
+	// an API call per running or stopped server on every poll.
+	switch server.Status { //nolint:exhaustive
+	case hcloud.ServerStatusInitializing,
+		hcloud.ServerStatusStarting,
+		hcloud.ServerStatusStopping,
+		hcloud.ServerStatusMigrating,
+		hcloud.ServerStatusRebuilding,
+		hcloud.ServerStatusDeleting:
+	default:
+		return
+	}
+
+	timeout := config.DefaultStuckTimeout
+	if c.cfg != nil {
+		timeout = c.cfg.GetStuckTimeout()
+	}
+
+	var fault string
+	action, _, err := c.api.GetLatestServerAction(ctx, server)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to retrieve server actions", "instance", server.ID, "error", err)
+	}
+	switch {
+	case action != nil && action.Status == hcloud.ActionStatusError:
+		fault = fmt.Sprintf("action %s failed: %s (%s)", action.Command, action.ErrorMessage, action.ErrorCode)
+	case action != nil && action.Status == hcloud.ActionStatusRunning && time.Since(action.Started) > timeout:
+		fault = fmt.Sprintf("action %s has been running since %s, server is %s", action.Command, action.Started.Format(time.RFC3339), server.Status)
+	case server.Status == hcloud.ServerStatusInitializing && time.Since(server.Created) > timeout:
+		fault = fmt.Sprintf("server has been initializing since %s", server.Created.Format(time.RFC3339))
+	default:
+		return
+	}
+
+	instance.Status = params.InstanceError
+	instance.ProviderFault = []byte(fault)
+}
+
+func (c *HcloudClient) GetInstancesByLabels(ctx context.Context, labels map[string]string) ([]*hcloud.Server, error) {
+	encoded, err := encodeLabels(labels)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get instances: %w", err)
 	}
+	servers, err := c.api.GetServersByLabel(ctx, labelSelector(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instances: %w", wrapAPIError(err))
+	}
 	return servers, nil
 }
 
+func labelSelector(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	selectors := make([]string, 0, len(keys))
+	for _, key := range keys {
+		selectors = append(selectors, key+"="+labels[key])
+	}
+	return strings.Join(selectors, ",")
+}
+
 func (c *HcloudClient) StartInstance(ctx context.Context, instance string) error {
 	server, err := c.GetInstance(ctx, instance, false)
 	if err != nil {
 		return err
 	}
-	if server.Status != hcloud.ServerStatusOff {
-		return fmt.Errorf("instance %s cannot be started in %s state", instance, server.Status)
+	switch server.Status {
+	case hcloud.ServerStatusOff:
+	case hcloud.ServerStatusRunning, hcloud.ServerStatusStarting, hcloud.ServerStatusInitializing:
+		slog.InfoContext(ctx, "instance already started", "instance", server.ID, "status", server.Status)
+		return nil
+	default:
+		return &InvalidStateError{Instance: instance, Operation: "started", Status: server.Status}
 	}
 	_, _, err = c.api.StartServer(ctx, server)
 	if err != nil {
-		return fmt.Errorf("error while starting: %v (ID: %d)", err, server.ID)
+		return fmt.Errorf("error while starting: %w (ID: %d)", wrapAPIError(err), server.ID)
 	}
 	return nil
 }
 
-func (c *HcloudClient) StopInstance(ctx context.Context, instance string) error {
+func (c *HcloudClient) StopInstance(ctx context.Context, instance string, force bool) error {
 	server, err := c.GetInstance(ctx, instance, false)
 	if err != nil {
 		return err
 	}
-	if server.Status != hcloud.ServerStatusRunning && server.Status != hcloud.ServerStatusStarting {
-		return fmt.Errorf("instance %s cannot be stopped in %s state", instance, server.Status)
+	switch server.Status {
+	case hcloud.ServerStatusRunning, hcloud.ServerStatusStarting:
+	case hcloud.ServerStatusOff, hcloud.ServerStatusStopping:
+		slog.InfoContext(ctx, "instance already stopped", "instance", server.ID, "status", server.Status)
+		return nil
+	default:
+		return &InvalidStateError{Instance: instance, Operation: "stopped", Status: server.Status}
+	}
+	if !force {
+		err := c.shutdown(ctx, server)
+		if err == nil {
+			return nil
+		}
+		slog.WarnContext(ctx, "graceful shutdown failed, powering off", "instance", server.ID, "error", err)
 	}
 	_, _, err = c.api.StopServer(ctx, server)
 	if err != nil {
-		return fmt.Errorf("error while stopping: %v (ID: %d)", err, server.ID)
+		return fmt.Errorf("error while stopping: %w (ID: %d)", wrapAPIError(err), server.ID)
 	}
 	return nil
 }
+
+// shutdown sends an ACPI shutdown request to the server and waits until it is
+// off, or the shutdown timeout expires.
+func (c *HcloudClient) shutdown(ctx context.Context, server *hcloud.Server) error {
+	if _, _, err := c.api.ShutdownServer(ctx, server); err != nil {
+		return fmt.Errorf("error while shutting down: %w", wrapAPIError(err))
+	}
+
+	timeout := config.DefaultShutdownTimeout
+	if c.cfg != nil {
+		timeout = c.cfg.GetShutdownTimeout()
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-waitCtx.Done():
+			return fmt.Errorf("server is still %s after %s", server.Status, timeout)
+		case <-ticker.C:
+		}
+		current, _, err := c.api.GetServer(waitCtx, strconv.FormatInt(server.ID, 10))
+		if err != nil {
+			return fmt.Errorf("error while retrieving the server: %w", wrapAPIError(err))
+		}
+		if current == nil || current.Status == hcloud.ServerStatusOff {
+			return nil
+		}
+		server = current
+	}
+}
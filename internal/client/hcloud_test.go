@@ -2,15 +2,35 @@ package client
 
 import (
 	"context"
+	"fmt"
 	"github.com/cloudbase/garm-provider-common/params"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	"github.com/imtf-group/garm-provider-hetzner/config"
 	"github.com/imtf-group/garm-provider-hetzner/internal/spec"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"net"
+	"strings"
 	"testing"
+	"time"
 )
 
+func mockPreflight(mockAPI *MockHCloudAPI) {
+	mockAPI.On("GetServerType", mock.Anything, "cx22").Return(&hcloud.ServerType{
+		Name:         "cx22",
+		Architecture: hcloud.ArchitectureX86,
+	}, &hcloud.Response{}, nil)
+	mockImage(mockAPI)
+}
+
+func mockImage(mockAPI *MockHCloudAPI) {
+	mockAPI.On("GetImage", mock.Anything, "ubuntu-22.04", hcloud.ArchitectureX86).Return(&hcloud.Image{
+		ID:           67794396,
+		Name:         "ubuntu-22.04",
+		Architecture: hcloud.ArchitectureX86,
+	}, &hcloud.Response{}, nil)
+}
+
 func TestDeserializeInstance(t *testing.T) {
 	server := &hcloud.Server{
 		ID:     123456,
@@ -22,103 +42,1181 @@ func TestDeserializeInstance(t *testing.T) {
 			"OSArch":       "amd64",
 		},
 	}
-	instance := DeserializeInstance(server)
-	expected := params.ProviderInstance{
-		ProviderID: "123456",
-		Name:       "garm-0000",
-		Status:     params.InstanceRunning,
-		OSType:     "linux",
-		OSArch:     "amd64",
+	instance := DeserializeInstance(server)
+	expected := params.ProviderInstance{
+		ProviderID: "123456",
+		Name:       "garm-0000",
+		Status:     params.InstanceRunning,
+		OSType:     "linux",
+		OSArch:     "amd64",
+	}
+	assert.Equal(t, instance, expected)
+}
+
+func TestDeserializeInstanceStatus(t *testing.T) {
+	tests := []struct {
+		status   hcloud.ServerStatus
+		expected params.InstanceStatus
+	}{
+		{hcloud.ServerStatusInitializing, params.InstanceCreating},
+		{hcloud.ServerStatusOff, params.InstanceStopped},
+		{hcloud.ServerStatusRunning, params.InstanceRunning},
+		{hcloud.ServerStatusStarting, params.InstanceStatusUnknown},
+		{hcloud.ServerStatusStopping, params.InstanceStatusUnknown},
+		{hcloud.ServerStatusMigrating, params.InstanceStatusUnknown},
+		{hcloud.ServerStatusRebuilding, params.InstanceStatusUnknown},
+		{hcloud.ServerStatusDeleting, params.InstanceDeleting},
+		{hcloud.ServerStatusUnknown, params.InstanceStatusUnknown},
+		{hcloud.ServerStatus("new_status"), params.InstanceStatusUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			instance := DeserializeInstance(&hcloud.Server{ID: 123456, Status: tt.status})
+			assert.Equal(t, tt.expected, instance.Status)
+		})
+	}
+}
+
+func TestDeserializeInstanceEncodedLabels(t *testing.T) {
+	name, err := encodeLabelValue("pool/garm runner")
+	assert.NoError(t, err)
+
+	server := &hcloud.Server{
+		ID:     123456,
+		Status: hcloud.ServerStatusRunning,
+		Labels: map[string]string{
+			"Name":   name,
+			"OSType": "linux",
+			"OSArch": "amd64",
+		},
+	}
+	instance := DeserializeInstance(server)
+	assert.Equal(t, instance.Name, "pool/garm runner")
+}
+
+func TestDeserializeInstanceAddresses(t *testing.T) {
+	server := &hcloud.Server{
+		ID:     123456,
+		Status: hcloud.ServerStatusRunning,
+		PublicNet: hcloud.ServerPublicNet{
+			IPv4: hcloud.ServerPublicNetIPv4{IP: net.ParseIP("192.0.2.10")},
+			IPv6: hcloud.ServerPublicNetIPv6{IP: net.ParseIP("2001:db8::")},
+		},
+		PrivateNet: []hcloud.ServerPrivateNet{
+			{Network: &hcloud.Network{ID: 22222}, IP: net.ParseIP("10.0.0.2")},
+			{Network: &hcloud.Network{ID: 33333}, IP: net.ParseIP("10.1.0.2")},
+		},
+	}
+	instance := DeserializeInstance(server)
+	assert.Equal(t, instance.Addresses, []params.Address{
+		{Address: "192.0.2.10", Type: params.PublicAddress},
+		{Address: "2001:db8::1", Type: params.PublicAddress},
+		{Address: "10.0.0.2", Type: params.PrivateAddress},
+		{Address: "10.1.0.2", Type: params.PrivateAddress},
+	})
+}
+
+func TestDeserializeInstanceNoPublicAddresses(t *testing.T) {
+	server := &hcloud.Server{
+		ID:     123456,
+		Status: hcloud.ServerStatusRunning,
+		PrivateNet: []hcloud.ServerPrivateNet{
+			{Network: &hcloud.Network{ID: 22222}, IP: net.ParseIP("10.0.0.2")},
+		},
+	}
+	instance := DeserializeInstance(server)
+	assert.Equal(t, instance.Addresses, []params.Address{
+		{Address: "10.0.0.2", Type: params.PrivateAddress},
+	})
+}
+
+func TestGetApi(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+	client := &HcloudClient{api: mockAPI}
+	assert.Equal(t, client.api, client.Api())
+}
+
+func TestSetApi(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+	client := &HcloudClient{}
+	client.SetApi(mockAPI)
+	assert.Equal(t, client.api, mockAPI)
+}
+
+func TestGetConfig(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+	client := &HcloudClient{
+		api: mockAPI,
+		cfg: &config.Config{
+			Location: config.Locations{"location"},
+			Token:    "token",
+		},
+	}
+	assert.Equal(t, client.cfg, client.Config())
+}
+
+func TestSetConfig(t *testing.T) {
+	client := &HcloudClient{}
+	cfg := &config.Config{
+		Location: config.Locations{"location"},
+		Token:    "token",
+	}
+	client.SetConfig(cfg)
+	assert.Equal(t, client.cfg, cfg)
+}
+
+func TestCreateInstance(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	Mocktools := params.RunnerApplicationDownload{
+		OS:           hcloud.Ptr("linux"),
+		Architecture: hcloud.Ptr("amd64"),
+		DownloadURL:  hcloud.Ptr("MockURL"),
+		Filename:     hcloud.Ptr("garm-runner"),
+	}
+	client := &HcloudClient{api: mockAPI}
+
+	spec := &spec.RunnerSpec{
+		Locations: []string{"fsn1"},
+		BootstrapParams: params.BootstrapInstance{
+			Name:   "test-runner",
+			PoolID: "pool-1",
+			OSType: "linux",
+			Flavor: "cx22",
+			Image:  "ubuntu-22.04",
+			OSArch: "amd64",
+		},
+		ControllerID:   "controller-xyz",
+		PlacementGroup: "111111",
+		Networks:       []spec.ResourceRef{"22222", "33333"},
+		Firewalls:      []spec.ResourceRef{"44444"},
+		Tools:          Mocktools,
+		DisableIPv6:    true,
+		Labels:         map[string]string{"team": "platform"},
+	}
+
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+	mockPreflight(mockAPI)
+	mockAPI.On("CreateServer", mock.Anything, mock.MatchedBy(func(opts hcloud.ServerCreateOpts) bool {
+		assert.Equal(t, opts.Location, &hcloud.Location{Name: "fsn1"})
+		assert.Equal(t, opts.Image.ID, int64(67794396))
+		assert.Equal(t, opts.Labels, map[string]string{
+			"Name":               "test-runner",
+			"GARM_POOL_ID":       "pool-1",
+			"OSType":             "linux",
+			"OSArch":             "amd64",
+			"GARM_CONTROLLER_ID": "controller-xyz",
+			"Location":           "fsn1",
+			"team":               "platform",
+		})
+		assert.NotNil(t, opts.PlacementGroup, 111111)
+		assert.Equal(t, opts.Networks, []*hcloud.Network{
+			&hcloud.Network{ID: 22222},
+			&hcloud.Network{ID: 33333},
+		})
+		assert.Equal(t, opts.Firewalls, []*hcloud.ServerCreateFirewall{
+			&hcloud.ServerCreateFirewall{
+				Firewall: hcloud.Firewall{ID: 44444},
+			},
+		})
+		assert.Equal(t, opts.PublicNet, &hcloud.ServerCreatePublicNet{
+			EnableIPv4: true,
+			EnableIPv6: false,
+		})
+		return true
+	})).Return(hcloud.ServerCreateResult{Server: &hcloud.Server{ID: 123456}}, &hcloud.Response{}, nil)
+
+	server, err := client.CreateInstance(context.Background(), spec)
+	assert.NoError(t, err)
+	assert.Equal(t, server.ID, int64(123456))
+	mockAPI.AssertExpectations(t)
+}
+
+func TestCreateInstanceEncodedLabels(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI}
+
+	spec := &spec.RunnerSpec{
+		Locations: []string{"fsn1"},
+		BootstrapParams: params.BootstrapInstance{
+			Name:   "garm runner",
+			PoolID: "pool-1",
+			OSType: "linux",
+			Flavor: "cx22",
+			Image:  "ubuntu-22.04",
+			OSArch: "amd64",
+		},
+		ControllerID: "controller-xyz",
+		Tools: params.RunnerApplicationDownload{
+			OS:           hcloud.Ptr("linux"),
+			Architecture: hcloud.Ptr("amd64"),
+			DownloadURL:  hcloud.Ptr("MockURL"),
+			Filename:     hcloud.Ptr("garm-runner"),
+		},
+	}
+
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+	mockPreflight(mockAPI)
+	mockAPI.On("CreateServer", mock.Anything, mock.MatchedBy(func(opts hcloud.ServerCreateOpts) bool {
+		return opts.Labels["Name"] == "enc.ctgn4r90e9qmsrj5e8"
+	})).Return(hcloud.ServerCreateResult{Server: &hcloud.Server{ID: 123456}}, &hcloud.Response{}, nil)
+
+	_, err := client.CreateInstance(context.Background(), spec)
+	assert.NoError(t, err)
+	mockAPI.AssertExpectations(t)
+}
+
+func TestCreateInstanceLabelTooLong(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI}
+
+	spec := &spec.RunnerSpec{
+		Locations: []string{"fsn1"},
+		BootstrapParams: params.BootstrapInstance{
+			Name:   strings.Repeat("a", 64),
+			PoolID: "pool-1",
+			OSType: "linux",
+			Flavor: "cx22",
+			Image:  "ubuntu-22.04",
+			OSArch: "amd64",
+		},
+		ControllerID: "controller-xyz",
+		Tools: params.RunnerApplicationDownload{
+			OS:           hcloud.Ptr("linux"),
+			Architecture: hcloud.Ptr("amd64"),
+			DownloadURL:  hcloud.Ptr("MockURL"),
+			Filename:     hcloud.Ptr("garm-runner"),
+		},
+	}
+
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+	mockPreflight(mockAPI)
+
+	server, err := client.CreateInstance(context.Background(), spec)
+	assert.Nil(t, server)
+	assert.ErrorContains(t, err, "failed to create instance: invalid value for label \"Name\"")
+	mockAPI.AssertNotCalled(t, "CreateServer", mock.Anything, mock.Anything)
+}
+
+func TestCreateInstanceWaitForCreate(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{
+		api: mockAPI,
+		cfg: &config.Config{
+			Location:      config.Locations{"fsn1"},
+			WaitForCreate: true,
+		},
+	}
+
+	spec := &spec.RunnerSpec{
+		Locations: []string{"fsn1"},
+		BootstrapParams: params.BootstrapInstance{
+			Name:   "test-runner",
+			PoolID: "pool-1",
+			OSType: "linux",
+			Flavor: "cx22",
+			Image:  "ubuntu-22.04",
+			OSArch: "amd64",
+		},
+		ControllerID: "controller-xyz",
+		Tools: params.RunnerApplicationDownload{
+			OS:           hcloud.Ptr("linux"),
+			Architecture: hcloud.Ptr("amd64"),
+			DownloadURL:  hcloud.Ptr("MockURL"),
+			Filename:     hcloud.Ptr("garm-runner"),
+		},
+	}
+
+	action := &hcloud.Action{ID: 1}
+	nextAction := &hcloud.Action{ID: 2}
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+	mockPreflight(mockAPI)
+	mockAPI.On("CreateServer", mock.Anything, mock.Anything).Return(hcloud.ServerCreateResult{
+		Server:      &hcloud.Server{ID: 123456, Status: hcloud.ServerStatusInitializing},
+		Action:      action,
+		NextActions: []*hcloud.Action{nextAction},
+	}, &hcloud.Response{}, nil)
+	mockAPI.On("WaitForActions", mock.Anything, []*hcloud.Action{action, nextAction}).Return(nil)
+	mockAPI.On("GetServer", mock.Anything, "123456").Return(&hcloud.Server{
+		ID:     123456,
+		Status: hcloud.ServerStatusRunning,
+	}, &hcloud.Response{}, nil)
+
+	server, err := client.CreateInstance(context.Background(), spec)
+	assert.NoError(t, err)
+	assert.Equal(t, server.ID, int64(123456))
+	assert.Equal(t, server.Status, hcloud.ServerStatusRunning)
+	mockAPI.AssertExpectations(t)
+}
+
+func TestCreateInstanceWaitForCreateError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		errString string
+		errIs     error
+	}{
+		{
+			name: "failed action",
+			err: hcloud.ActionError{
+				Code:    "server_create_failed",
+				Message: "server creation failed",
+			},
+			errString: "server creation failed",
+		},
+		{
+			name: "rate limited",
+			err: hcloud.Error{
+				Code:    hcloud.ErrorCodeRateLimitExceeded,
+				Message: "limit of 3600 requests per hour reached",
+			},
+			errString: "limit of 3600 requests per hour reached",
+			errIs:     ErrRateLimited,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAPI := new(MockHCloudAPI)
+
+			client := &HcloudClient{
+				api: mockAPI,
+				cfg: &config.Config{
+					Location:      config.Locations{"fsn1"},
+					WaitForCreate: true,
+				},
+			}
+
+			spec := &spec.RunnerSpec{
+				Locations: []string{"fsn1"},
+				BootstrapParams: params.BootstrapInstance{
+					Name:   "test-runner",
+					PoolID: "pool-1",
+					OSType: "linux",
+					Flavor: "cx22",
+					Image:  "ubuntu-22.04",
+					OSArch: "amd64",
+				},
+				ControllerID: "controller-xyz",
+				Tools: params.RunnerApplicationDownload{
+					OS:           hcloud.Ptr("linux"),
+					Architecture: hcloud.Ptr("amd64"),
+					DownloadURL:  hcloud.Ptr("MockURL"),
+					Filename:     hcloud.Ptr("garm-runner"),
+				},
+			}
+
+			mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+			mockPreflight(mockAPI)
+			mockAPI.On("CreateServer", mock.Anything, mock.Anything).Return(hcloud.ServerCreateResult{
+				Server: &hcloud.Server{ID: 123456, Status: hcloud.ServerStatusInitializing},
+				Action: &hcloud.Action{ID: 1},
+			}, &hcloud.Response{}, nil)
+			mockAPI.On("WaitForActions", mock.Anything, mock.Anything).Return(tt.err)
+
+			mockAPI.On("DeleteServer", mock.Anything, mock.MatchedBy(func(server *hcloud.Server) bool {
+				return server.ID == 123456
+			})).Return(&hcloud.Response{}, nil)
+
+			server, err := client.CreateInstance(context.Background(), spec)
+			assert.Error(t, err)
+			assert.Nil(t, server)
+			assert.Contains(t, err.Error(), tt.errString)
+			if tt.errIs != nil {
+				assert.ErrorIs(t, err, tt.errIs)
+			}
+			mockAPI.AssertExpectations(t)
+			mockAPI.AssertNotCalled(t, "GetServer", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCreateInstanceRollbackError(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{
+		api: mockAPI,
+		cfg: &config.Config{
+			Location:      config.Locations{"fsn1"},
+			WaitForCreate: true,
+		},
+	}
+
+	spec := &spec.RunnerSpec{
+		Locations: []string{"fsn1"},
+		BootstrapParams: params.BootstrapInstance{
+			Name:   "test-runner",
+			PoolID: "pool-1",
+			OSType: "linux",
+			Flavor: "cx22",
+			Image:  "ubuntu-22.04",
+			OSArch: "amd64",
+		},
+		ControllerID: "controller-xyz",
+		Tools: params.RunnerApplicationDownload{
+			OS:           hcloud.Ptr("linux"),
+			Architecture: hcloud.Ptr("amd64"),
+			DownloadURL:  hcloud.Ptr("MockURL"),
+			Filename:     hcloud.Ptr("garm-runner"),
+		},
+	}
+
+	waitErr := hcloud.ActionError{
+		Code:    "server_create_failed",
+		Message: "server creation failed",
+	}
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+	mockPreflight(mockAPI)
+	mockAPI.On("CreateServer", mock.Anything, mock.Anything).Return(hcloud.ServerCreateResult{
+		Server: &hcloud.Server{ID: 123456, Status: hcloud.ServerStatusInitializing},
+		Action: &hcloud.Action{ID: 1},
+	}, &hcloud.Response{}, nil)
+	mockAPI.On("WaitForActions", mock.Anything, mock.Anything).Return(waitErr)
+	mockAPI.On("DeleteServer", mock.Anything, mock.Anything).Return(&hcloud.Response{}, fmt.Errorf("server is locked"))
+
+	server, err := client.CreateInstance(context.Background(), spec)
+	assert.Error(t, err)
+	assert.Nil(t, server)
+	assert.ErrorIs(t, err, waitErr)
+	assert.Contains(t, err.Error(), "failed to roll back instance: server is locked (ID: 123456)")
+	mockAPI.AssertExpectations(t)
+}
+
+func TestCreateInstanceLocationFallback(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI}
+
+	spec := &spec.RunnerSpec{
+		Locations: []string{"nbg1", "fsn1", "hel1"},
+		BootstrapParams: params.BootstrapInstance{
+			Name:   "test-runner",
+			PoolID: "pool-1",
+			OSType: "linux",
+			Flavor: "cx22",
+			Image:  "ubuntu-22.04",
+			OSArch: "amd64",
+		},
+		ControllerID: "controller-xyz",
+		Tools: params.RunnerApplicationDownload{
+			OS:           hcloud.Ptr("linux"),
+			Architecture: hcloud.Ptr("amd64"),
+			DownloadURL:  hcloud.Ptr("MockURL"),
+			Filename:     hcloud.Ptr("garm-runner"),
+		},
+	}
+
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+	mockPreflight(mockAPI)
+	mockAPI.On("CreateServer", mock.Anything, mock.MatchedBy(func(opts hcloud.ServerCreateOpts) bool {
+		return opts.Location.Name == "nbg1"
+	})).Return(hcloud.ServerCreateResult{}, &hcloud.Response{}, hcloud.Error{
+		Code:    hcloud.ErrorCodeResourceUnavailable,
+		Message: "server type cx22 is unavailable",
+	}).Once()
+	mockAPI.On("CreateServer", mock.Anything, mock.MatchedBy(func(opts hcloud.ServerCreateOpts) bool {
+		return opts.Location.Name == "fsn1" && opts.Labels["Location"] == "fsn1"
+	})).Return(hcloud.ServerCreateResult{Server: &hcloud.Server{ID: 123456}}, &hcloud.Response{}, nil).Once()
+
+	server, err := client.CreateInstance(context.Background(), spec)
+	assert.NoError(t, err)
+	assert.Equal(t, server.ID, int64(123456))
+	mockAPI.AssertExpectations(t)
+	mockAPI.AssertNumberOfCalls(t, "CreateServer", 2)
+}
+
+func TestCreateInstanceLocationFallbackExhausted(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI}
+
+	spec := &spec.RunnerSpec{
+		Locations: []string{"nbg1", "fsn1"},
+		BootstrapParams: params.BootstrapInstance{
+			Name:   "test-runner",
+			PoolID: "pool-1",
+			OSType: "linux",
+			Flavor: "cx22",
+			Image:  "ubuntu-22.04",
+			OSArch: "amd64",
+		},
+		ControllerID: "controller-xyz",
+		Tools: params.RunnerApplicationDownload{
+			OS:           hcloud.Ptr("linux"),
+			Architecture: hcloud.Ptr("amd64"),
+			DownloadURL:  hcloud.Ptr("MockURL"),
+			Filename:     hcloud.Ptr("garm-runner"),
+		},
+	}
+
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+	mockPreflight(mockAPI)
+	mockAPI.On("CreateServer", mock.Anything, mock.Anything).Return(hcloud.ServerCreateResult{}, &hcloud.Response{}, hcloud.Error{
+		Code:    hcloud.ErrorCodeResourceUnavailable,
+		Message: "server type cx22 is unavailable",
+	})
+
+	server, err := client.CreateInstance(context.Background(), spec)
+	assert.Error(t, err)
+	assert.Nil(t, server)
+	assert.Contains(t, err.Error(), "server type cx22 is unavailable")
+	mockAPI.AssertNumberOfCalls(t, "CreateServer", 2)
+}
+
+func TestCreateInstanceNoFallbackOnOtherErrors(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI}
+
+	spec := &spec.RunnerSpec{
+		Locations: []string{"nbg1", "fsn1"},
+		BootstrapParams: params.BootstrapInstance{
+			Name:   "test-runner",
+			PoolID: "pool-1",
+			OSType: "linux",
+			Flavor: "cx22",
+			Image:  "ubuntu-22.04",
+			OSArch: "amd64",
+		},
+		ControllerID: "controller-xyz",
+		Tools: params.RunnerApplicationDownload{
+			OS:           hcloud.Ptr("linux"),
+			Architecture: hcloud.Ptr("amd64"),
+			DownloadURL:  hcloud.Ptr("MockURL"),
+			Filename:     hcloud.Ptr("garm-runner"),
+		},
+	}
+
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+	mockPreflight(mockAPI)
+	mockAPI.On("CreateServer", mock.Anything, mock.Anything).Return(hcloud.ServerCreateResult{}, &hcloud.Response{}, hcloud.Error{
+		Code:    hcloud.ErrorCodeInvalidInput,
+		Message: "invalid input in field 'image'",
+	})
+
+	_, err := client.CreateInstance(context.Background(), spec)
+	assert.Error(t, err)
+	mockAPI.AssertNumberOfCalls(t, "CreateServer", 1)
+}
+
+func TestCreateInstanceServerTypeFallback(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI}
+
+	spec := &spec.RunnerSpec{
+		Locations: []string{"nbg1", "fsn1"},
+		BootstrapParams: params.BootstrapInstance{
+			Name:   "test-runner",
+			PoolID: "pool-1",
+			OSType: "linux",
+			Flavor: "cx22",
+			Image:  "ubuntu-22.04",
+			OSArch: "amd64",
+		},
+		ServerTypeFallbacks: []string{"cpx31", "cx32"},
+		ControllerID:        "controller-xyz",
+		Tools: params.RunnerApplicationDownload{
+			OS:           hcloud.Ptr("linux"),
+			Architecture: hcloud.Ptr("amd64"),
+			DownloadURL:  hcloud.Ptr("MockURL"),
+			Filename:     hcloud.Ptr("garm-runner"),
+		},
+	}
+
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+	mockImage(mockAPI)
+	mockAPI.On("GetServerType", mock.Anything, "cx22").Return(&hcloud.ServerType{
+		Name:         "cx22",
+		Architecture: hcloud.ArchitectureX86,
+		Locations: []hcloud.ServerTypeLocation{
+			{
+				Location: &hcloud.Location{Name: "nbg1"},
+				DeprecatableResource: hcloud.DeprecatableResource{
+					Deprecation: &hcloud.DeprecationInfo{UnavailableAfter: time.Now().Add(-time.Hour)},
+				},
+			},
+		},
+	}, &hcloud.Response{}, nil)
+	mockAPI.On("GetServerType", mock.Anything, "cpx31").Return(&hcloud.ServerType{Name: "cpx31", Architecture: hcloud.ArchitectureX86}, &hcloud.Response{}, nil)
+	mockAPI.On("GetServerType", mock.Anything, "cx32").Return(&hcloud.ServerType{Name: "cx32", Architecture: hcloud.ArchitectureX86}, &hcloud.Response{}, nil)
+	mockAPI.On("CreateServer", mock.Anything, mock.MatchedBy(func(opts hcloud.ServerCreateOpts) bool {
+		return opts.ServerType.Name == "cpx31"
+	})).Return(hcloud.ServerCreateResult{}, &hcloud.Response{}, hcloud.Error{
+		Code:    hcloud.ErrorCodeResourceUnavailable,
+		Message: "server type cpx31 is unavailable",
+	}).Twice()
+	mockAPI.On("CreateServer", mock.Anything, mock.MatchedBy(func(opts hcloud.ServerCreateOpts) bool {
+		return opts.ServerType.Name == "cx32" && opts.Location.Name == "nbg1"
+	})).Return(hcloud.ServerCreateResult{Server: &hcloud.Server{ID: 123456}}, &hcloud.Response{}, nil).Once()
+
+	server, err := client.CreateInstance(context.Background(), spec)
+	assert.NoError(t, err)
+	assert.Equal(t, server.ID, int64(123456))
+	mockAPI.AssertExpectations(t)
+	mockAPI.AssertNumberOfCalls(t, "CreateServer", 3)
+	mockAPI.AssertNotCalled(t, "CreateServer", mock.Anything, mock.MatchedBy(func(opts hcloud.ServerCreateOpts) bool {
+		return opts.ServerType.Name == "cx22"
+	}))
+}
+
+func TestCreateInstanceServerTypeFallbackArchMismatch(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI}
+
+	spec := &spec.RunnerSpec{
+		Locations: []string{"nbg1"},
+		BootstrapParams: params.BootstrapInstance{
+			Name:   "test-runner",
+			PoolID: "pool-1",
+			OSType: "linux",
+			Flavor: "cx22",
+			Image:  "ubuntu-22.04",
+			OSArch: "amd64",
+		},
+		ServerTypeFallbacks: []string{"cax21"},
+		ControllerID:        "controller-xyz",
+	}
+
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+	mockPreflight(mockAPI)
+	mockAPI.On("GetServerType", mock.Anything, "cax21").Return(&hcloud.ServerType{Name: "cax21", Architecture: hcloud.ArchitectureARM}, &hcloud.Response{}, nil)
+
+	server, err := client.CreateInstance(context.Background(), spec)
+	assert.Error(t, err)
+	assert.Nil(t, server)
+	assert.Equal(t, err.Error(), "server type \"cax21\" has arm architecture, pool requires x86")
+	mockAPI.AssertNotCalled(t, "CreateServer", mock.Anything, mock.Anything)
+}
+
+func TestCreateInstanceServerTypeFallbackNotFound(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI}
+
+	spec := &spec.RunnerSpec{
+		Locations: []string{"nbg1"},
+		BootstrapParams: params.BootstrapInstance{
+			Name:   "test-runner",
+			PoolID: "pool-1",
+			OSType: "linux",
+			Flavor: "cx22",
+			Image:  "ubuntu-22.04",
+			OSArch: "amd64",
+		},
+		ServerTypeFallbacks: []string{"cx99", "cx23"},
+		ControllerID:        "controller-xyz",
+		Tools: params.RunnerApplicationDownload{
+			OS:           hcloud.Ptr("linux"),
+			Architecture: hcloud.Ptr("amd64"),
+			DownloadURL:  hcloud.Ptr("MockURL"),
+			Filename:     hcloud.Ptr("garm-runner"),
+		},
+	}
+
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+	mockImage(mockAPI)
+	mockAPI.On("GetServerType", mock.Anything, "cx22").Return(nil, &hcloud.Response{}, nil)
+	mockAPI.On("GetServerType", mock.Anything, "cx99").Return(nil, &hcloud.Response{}, nil)
+	mockAPI.On("GetServerType", mock.Anything, "cx23").Return(&hcloud.ServerType{Name: "cx23", Architecture: hcloud.ArchitectureX86}, &hcloud.Response{}, nil)
+	mockAPI.On("CreateServer", mock.Anything, mock.MatchedBy(func(opts hcloud.ServerCreateOpts) bool {
+		return opts.ServerType.Name == "cx23"
+	})).Return(hcloud.ServerCreateResult{Server: &hcloud.Server{ID: 123456}}, &hcloud.Response{}, nil).Once()
+
+	server, err := client.CreateInstance(context.Background(), spec)
+	assert.NoError(t, err)
+	assert.Equal(t, server.ID, int64(123456))
+	mockAPI.AssertExpectations(t)
+	mockAPI.AssertNumberOfCalls(t, "CreateServer", 1)
+}
+
+func TestCreateInstanceNoServerTypeAvailable(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI}
+
+	spec := &spec.RunnerSpec{
+		Locations: []string{"nbg1"},
+		BootstrapParams: params.BootstrapInstance{
+			Name:   "test-runner",
+			PoolID: "pool-1",
+			OSType: "linux",
+			Flavor: "cx22",
+			Image:  "ubuntu-22.04",
+			OSArch: "amd64",
+		},
+		ServerTypeFallbacks: []string{"cx99"},
+		ControllerID:        "controller-xyz",
+	}
+
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+	mockAPI.On("GetServerType", mock.Anything, "cx22").Return(&hcloud.ServerType{
+		Name:         "cx22",
+		Architecture: hcloud.ArchitectureX86,
+		DeprecatableResource: hcloud.DeprecatableResource{
+			Deprecation: &hcloud.DeprecationInfo{UnavailableAfter: time.Now().Add(-time.Hour)},
+		},
+	}, &hcloud.Response{}, nil)
+	mockAPI.On("GetServerType", mock.Anything, "cx99").Return(nil, &hcloud.Response{}, nil)
+
+	_, err := client.CreateInstance(context.Background(), spec)
+	assert.EqualError(t, err, "no available server type among cx22, cx99")
+	assert.ErrorIs(t, err, ErrNotFound)
+	mockAPI.AssertNotCalled(t, "CreateServer", mock.Anything, mock.Anything)
+}
+
+func TestServerTypeAvailable(t *testing.T) {
+	now := time.Now()
+	past := &hcloud.DeprecationInfo{UnavailableAfter: now.Add(-time.Hour)}
+	future := &hcloud.DeprecationInfo{UnavailableAfter: now.Add(time.Hour)}
+
+	tests := []struct {
+		name       string
+		serverType *hcloud.ServerType
+		locations  []string
+		available  bool
+	}{
+		{
+			name:       "not deprecated",
+			serverType: &hcloud.ServerType{},
+			locations:  []string{"nbg1"},
+			available:  true,
+		},
+		{
+			name:       "deprecated but still available",
+			serverType: &hcloud.ServerType{DeprecatableResource: hcloud.DeprecatableResource{Deprecation: future}},
+			locations:  []string{"nbg1"},
+			available:  true,
+		},
+		{
+			name:       "past unavailability date",
+			serverType: &hcloud.ServerType{DeprecatableResource: hcloud.DeprecatableResource{Deprecation: past}},
+			locations:  []string{"nbg1"},
+			available:  false,
+		},
+		{
+			name: "available in another location",
+			serverType: &hcloud.ServerType{Locations: []hcloud.ServerTypeLocation{
+				{Location: &hcloud.Location{Name: "nbg1"}, DeprecatableResource: hcloud.DeprecatableResource{Deprecation: past}},
+				{Location: &hcloud.Location{Name: "fsn1"}},
+			}},
+			locations: []string{"nbg1", "fsn1"},
+			available: true,
+		},
+		{
+			name: "unavailable in every location",
+			serverType: &hcloud.ServerType{Locations: []hcloud.ServerTypeLocation{
+				{Location: &hcloud.Location{Name: "nbg1"}, DeprecatableResource: hcloud.DeprecatableResource{Deprecation: past}},
+				{Location: &hcloud.Location{Name: "fsn1"}},
+			}},
+			locations: []string{"nbg1"},
+			available: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.available, serverTypeAvailable(tt.serverType, tt.locations, now))
+		})
 	}
-	assert.Equal(t, instance, expected)
 }
 
-func TestGetApi(t *testing.T) {
-	mockAPI := new(MockHCloudAPI)
-	client := &HcloudClient{api: mockAPI}
-	assert.Equal(t, client.api, client.Api())
-}
+func TestCreateInstanceArchitectureMismatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		osArch     params.OSArch
+		serverType *hcloud.ServerType
+		image      *hcloud.Image
+		errString  string
+	}{
+		{
+			name:       "x86 flavor on arm64 pool",
+			osArch:     params.Arm64,
+			serverType: &hcloud.ServerType{Name: "cx22", Architecture: hcloud.ArchitectureX86},
+			errString:  "server type \"cx22\" has x86 architecture, pool requires arm",
+		},
+		{
+			name:       "missing image for architecture",
+			osArch:     params.Amd64,
+			serverType: &hcloud.ServerType{Name: "cx22", Architecture: hcloud.ArchitectureX86},
+			errString:  "image \"ubuntu-22.04\" not found for x86 architecture",
+		},
+		{
+			name:       "arm image on amd64 pool",
+			osArch:     params.Amd64,
+			serverType: &hcloud.ServerType{Name: "cx22", Architecture: hcloud.ArchitectureX86},
+			image:      &hcloud.Image{ID: 103908070, Architecture: hcloud.ArchitectureARM},
+			errString:  "image \"ubuntu-22.04\" has arm architecture, pool requires x86",
+		},
+		{
+			name:      "unsupported architecture",
+			osArch:    params.I386,
+			errString: "unsupported architecture: \"i386\"",
+		},
+	}
 
-func TestSetApi(t *testing.T) {
-	mockAPI := new(MockHCloudAPI)
-	client := &HcloudClient{}
-	client.SetApi(mockAPI)
-	assert.Equal(t, client.api, mockAPI)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAPI := new(MockHCloudAPI)
+
+			client := &HcloudClient{api: mockAPI}
+
+			spec := &spec.RunnerSpec{
+				Locations: []string{"nbg1"},
+				BootstrapParams: params.BootstrapInstance{
+					Name:   "test-runner",
+					PoolID: "pool-1",
+					OSType: "linux",
+					Flavor: "cx22",
+					Image:  "ubuntu-22.04",
+					OSArch: tt.osArch,
+				},
+				ControllerID: "controller-xyz",
+			}
+
+			mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+			mockAPI.On("GetServerType", mock.Anything, "cx22").Return(tt.serverType, &hcloud.Response{}, nil)
+			mockAPI.On("GetImage", mock.Anything, "ubuntu-22.04", mock.Anything).Return(tt.image, &hcloud.Response{}, nil)
+
+			server, err := client.CreateInstance(context.Background(), spec)
+			assert.Error(t, err)
+			assert.Nil(t, server)
+			assert.Equal(t, err.Error(), tt.errString)
+			mockAPI.AssertNotCalled(t, "CreateServer", mock.Anything, mock.Anything)
+		})
+	}
 }
 
-func TestGetConfig(t *testing.T) {
-	mockAPI := new(MockHCloudAPI)
-	client := &HcloudClient{
-		api: mockAPI,
-		cfg: &config.Config{
-			Location: "location",
-			Token:    "token",
+func TestCreateInstanceImageRef(t *testing.T) {
+	older := &hcloud.Image{ID: 1001, Architecture: hcloud.ArchitectureX86, Created: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	newer := &hcloud.Image{ID: 1002, Architecture: hcloud.ArchitectureX86, Created: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)}
+	arm := &hcloud.Image{ID: 1003, Architecture: hcloud.ArchitectureARM, Created: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name      string
+		image     string
+		setup     func(mockAPI *MockHCloudAPI)
+		lookup    string
+		imageID   int64
+		errString string
+	}{
+		{
+			name:  "plain name",
+			image: "ubuntu-22.04",
+			setup: func(mockAPI *MockHCloudAPI) {
+				mockAPI.On("GetImage", mock.Anything, "ubuntu-22.04", hcloud.ArchitectureX86).Return(&hcloud.Image{ID: 67794396, Architecture: hcloud.ArchitectureX86}, &hcloud.Response{}, nil)
+			},
+			lookup:  "GetImage",
+			imageID: 67794396,
+		},
+		{
+			name:  "id",
+			image: "id:1001",
+			setup: func(mockAPI *MockHCloudAPI) {
+				mockAPI.On("GetImageByID", mock.Anything, int64(1001)).Return(older, &hcloud.Response{}, nil)
+			},
+			lookup:  "GetImageByID",
+			imageID: 1001,
+		},
+		{
+			name:  "id not found",
+			image: "id:1001",
+			setup: func(mockAPI *MockHCloudAPI) {
+				mockAPI.On("GetImageByID", mock.Anything, int64(1001)).Return(nil, &hcloud.Response{}, nil)
+			},
+			errString: "image \"id:1001\" not found for x86 architecture",
+		},
+		{
+			name:      "invalid id",
+			image:     "id:runner",
+			setup:     func(mockAPI *MockHCloudAPI) {},
+			errString: "invalid image ID \"runner\"",
+		},
+		{
+			name:  "name",
+			image: "name:runner-image",
+			setup: func(mockAPI *MockHCloudAPI) {
+				mockAPI.On("GetImageByName", mock.Anything, "runner-image", hcloud.ArchitectureX86).Return(newer, &hcloud.Response{}, nil)
+			},
+			lookup:  "GetImageByName",
+			imageID: 1002,
+		},
+		{
+			name:  "label selector picks newest snapshot",
+			image: "label:role=runner",
+			setup: func(mockAPI *MockHCloudAPI) {
+				mockAPI.On("GetImagesByLabel", mock.Anything, "role=runner", hcloud.ArchitectureX86).Return([]*hcloud.Image{older, arm, newer}, nil)
+			},
+			imageID: 1002,
+		},
+		{
+			name:  "label selector without match",
+			image: "label:role=runner",
+			setup: func(mockAPI *MockHCloudAPI) {
+				mockAPI.On("GetImagesByLabel", mock.Anything, "role=runner", hcloud.ArchitectureX86).Return([]*hcloud.Image{arm}, nil)
+			},
+			errString: "no x86 image matches label selector \"role=runner\"",
+		},
+		{
+			name:  "label selector error",
+			image: "label:role=runner",
+			setup: func(mockAPI *MockHCloudAPI) {
+				mockAPI.On("GetImagesByLabel", mock.Anything, "role=runner", hcloud.ArchitectureX86).Return([]*hcloud.Image{}, fmt.Errorf("API error"))
+			},
+			errString: "error while retrieving images matching \"role=runner\": API error",
 		},
 	}
-	assert.Equal(t, client.cfg, client.Config())
-}
 
-func TestSetConfig(t *testing.T) {
-	client := &HcloudClient{}
-	cfg := &config.Config{
-		Location: "location",
-		Token:    "token",
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAPI := new(MockHCloudAPI)
+
+			client := &HcloudClient{api: mockAPI}
+
+			spec := &spec.RunnerSpec{
+				Locations: []string{"nbg1"},
+				BootstrapParams: params.BootstrapInstance{
+					Name:   "test-runner",
+					PoolID: "pool-1",
+					OSType: "linux",
+					Flavor: "cx22",
+					Image:  tt.image,
+					OSArch: params.Amd64,
+				},
+				Tools: params.RunnerApplicationDownload{
+					OS:           hcloud.Ptr("linux"),
+					Architecture: hcloud.Ptr("amd64"),
+					DownloadURL:  hcloud.Ptr("MockURL"),
+					Filename:     hcloud.Ptr("garm-runner"),
+				},
+				ControllerID: "controller-xyz",
+			}
+
+			mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+			mockAPI.On("GetServerType", mock.Anything, "cx22").Return(&hcloud.ServerType{Name: "cx22", Architecture: hcloud.ArchitectureX86}, &hcloud.Response{}, nil)
+			tt.setup(mockAPI)
+			mockAPI.On("CreateServer", mock.Anything, mock.MatchedBy(func(opts hcloud.ServerCreateOpts) bool {
+				return opts.Image.ID == tt.imageID
+			})).Return(hcloud.ServerCreateResult{
+				Server: &hcloud.Server{ID: 123456},
+			}, &hcloud.Response{}, nil)
+
+			server, err := client.CreateInstance(context.Background(), spec)
+			if tt.errString != "" {
+				assert.EqualError(t, err, tt.errString)
+				assert.Nil(t, server)
+				mockAPI.AssertNotCalled(t, "CreateServer", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, server.ID, int64(123456))
+			mockAPI.AssertExpectations(t)
+			for _, call := range mockAPI.Calls {
+				if strings.HasPrefix(call.Method, "GetImage") && call.Method != "GetImagesByLabel" {
+					assert.Equal(t, tt.lookup, call.Method)
+				}
+			}
+		})
 	}
-	client.SetConfig(cfg)
-	assert.Equal(t, client.cfg, cfg)
 }
 
-func TestCreateInstance(t *testing.T) {
+func TestCreateInstanceResolveResources(t *testing.T) {
 	mockAPI := new(MockHCloudAPI)
 
-	Mocktools := params.RunnerApplicationDownload{
-		OS:           hcloud.Ptr("linux"),
-		Architecture: hcloud.Ptr("amd64"),
-		DownloadURL:  hcloud.Ptr("MockURL"),
-		Filename:     hcloud.Ptr("garm-runner"),
-	}
 	client := &HcloudClient{api: mockAPI}
 
 	spec := &spec.RunnerSpec{
-		Location: "fsn1",
+		Locations: []string{"fsn1"},
 		BootstrapParams: params.BootstrapInstance{
 			Name:   "test-runner",
 			PoolID: "pool-1",
 			OSType: "linux",
 			Flavor: "cx22",
+			Image:  "ubuntu-22.04",
 			OSArch: "amd64",
 		},
 		ControllerID:   "controller-xyz",
-		PlacementGroup: 111111,
-		Networks:       []int64{22222, 33333},
-		Firewalls:      []int64{44444},
-		Tools:          Mocktools,
-		DisableIPv6:    true,
+		SSHKeys:        []spec.ResourceRef{"deploy", "123"},
+		PlacementGroup: "spread",
+		Networks:       []spec.ResourceRef{"label:env=prod"},
+		Firewalls:      []spec.ResourceRef{"runners"},
+		Tools: params.RunnerApplicationDownload{
+			OS:           hcloud.Ptr("linux"),
+			Architecture: hcloud.Ptr("amd64"),
+			DownloadURL:  hcloud.Ptr("MockURL"),
+			Filename:     hcloud.Ptr("garm-runner"),
+		},
 	}
 
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+	mockPreflight(mockAPI)
+	mockAPI.On("GetSSHKey", mock.Anything, "deploy").Return(&hcloud.SSHKey{ID: 456, Name: "deploy"}, &hcloud.Response{}, nil)
+	mockAPI.On("GetNetworksByLabel", mock.Anything, "env=prod").Return([]*hcloud.Network{
+		&hcloud.Network{ID: 10},
+		&hcloud.Network{ID: 11},
+	}, nil)
+	mockAPI.On("GetFirewall", mock.Anything, "runners").Return(&hcloud.Firewall{ID: 20, Name: "runners"}, &hcloud.Response{}, nil)
+	mockAPI.On("GetPlacementGroup", mock.Anything, "spread").Return(&hcloud.PlacementGroup{ID: 30, Name: "spread"}, &hcloud.Response{}, nil)
 	mockAPI.On("CreateServer", mock.Anything, mock.MatchedBy(func(opts hcloud.ServerCreateOpts) bool {
-		assert.NotNil(t, opts.Location, "fsn1")
-		assert.NotNil(t, opts.PlacementGroup, 111111)
+		assert.Equal(t, opts.SSHKeys, []*hcloud.SSHKey{
+			&hcloud.SSHKey{ID: 456, Name: "deploy"},
+			&hcloud.SSHKey{ID: 123},
+		})
 		assert.Equal(t, opts.Networks, []*hcloud.Network{
-			&hcloud.Network{ID: 22222},
-			&hcloud.Network{ID: 33333},
+			&hcloud.Network{ID: 10},
+			&hcloud.Network{ID: 11},
 		})
 		assert.Equal(t, opts.Firewalls, []*hcloud.ServerCreateFirewall{
 			&hcloud.ServerCreateFirewall{
-				Firewall: hcloud.Firewall{ID: 44444},
+				Firewall: hcloud.Firewall{ID: 20},
 			},
 		})
-		assert.Equal(t, opts.PublicNet, &hcloud.ServerCreatePublicNet{
-			EnableIPv4: true,
-			EnableIPv6: false,
-		})
+		assert.Equal(t, opts.PlacementGroup, &hcloud.PlacementGroup{ID: 30, Name: "spread"})
 		return true
 	})).Return(hcloud.ServerCreateResult{Server: &hcloud.Server{ID: 123456}}, &hcloud.Response{}, nil)
 
-	serverID, err := client.CreateInstance(context.Background(), spec)
+	server, err := client.CreateInstance(context.Background(), spec)
+	assert.NoError(t, err)
+	assert.Equal(t, server.ID, int64(123456))
+	mockAPI.AssertExpectations(t)
+}
+
+func TestCreateInstanceResolveResourcesError(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      *spec.RunnerSpec
+		mock      func(mockAPI *MockHCloudAPI)
+		errString string
+	}{
+		{
+			name: "unknown ssh key",
+			spec: &spec.RunnerSpec{SSHKeys: []spec.ResourceRef{"deploy"}},
+			mock: func(mockAPI *MockHCloudAPI) {
+				mockAPI.On("GetSSHKey", mock.Anything, "deploy").Return(nil, &hcloud.Response{}, nil)
+			},
+			errString: "SSH key \"deploy\" not found",
+		},
+		{
+			name: "no network matching label selector",
+			spec: &spec.RunnerSpec{Networks: []spec.ResourceRef{"label:env=prod"}},
+			mock: func(mockAPI *MockHCloudAPI) {
+				mockAPI.On("GetNetworksByLabel", mock.Anything, "env=prod").Return([]*hcloud.Network{}, nil)
+			},
+			errString: "no network matches label selector \"env=prod\"",
+		},
+		{
+			name: "firewall lookup error",
+			spec: &spec.RunnerSpec{Firewalls: []spec.ResourceRef{"runners"}},
+			mock: func(mockAPI *MockHCloudAPI) {
+				mockAPI.On("GetFirewall", mock.Anything, "runners").Return(nil, &hcloud.Response{}, fmt.Errorf("forbidden"))
+			},
+			errString: "error while retrieving firewall \"runners\": forbidden",
+		},
+		{
+			name: "placement group label selector matching several groups",
+			spec: &spec.RunnerSpec{PlacementGroup: "label:env=prod"},
+			mock: func(mockAPI *MockHCloudAPI) {
+				mockAPI.On("GetPlacementGroupsByLabel", mock.Anything, "env=prod").Return([]*hcloud.PlacementGroup{
+					&hcloud.PlacementGroup{ID: 1},
+					&hcloud.PlacementGroup{ID: 2},
+				}, nil)
+			},
+			errString: "placement group \"label:env=prod\" matches 2 placement groups",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAPI := new(MockHCloudAPI)
+
+			client := &HcloudClient{api: mockAPI}
+
+			tt.spec.Locations = []string{"fsn1"}
+			tt.spec.ControllerID = "controller-xyz"
+			tt.spec.BootstrapParams = params.BootstrapInstance{
+				Name:   "test-runner",
+				PoolID: "pool-1",
+				OSType: "linux",
+				Flavor: "cx22",
+				Image:  "ubuntu-22.04",
+				OSArch: "amd64",
+			}
+			tt.spec.Tools = params.RunnerApplicationDownload{
+				OS:           hcloud.Ptr("linux"),
+				Architecture: hcloud.Ptr("amd64"),
+				DownloadURL:  hcloud.Ptr("MockURL"),
+				Filename:     hcloud.Ptr("garm-runner"),
+			}
+
+			mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, nil)
+			mockPreflight(mockAPI)
+			tt.mock(mockAPI)
+
+			server, err := client.CreateInstance(context.Background(), tt.spec)
+			assert.Error(t, err)
+			assert.Nil(t, server)
+			assert.Equal(t, err.Error(), tt.errString)
+			mockAPI.AssertNotCalled(t, "CreateServer", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCreateInstanceAdoptExisting(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI}
+
+	spec := &spec.RunnerSpec{
+		Locations: []string{"fsn1"},
+		BootstrapParams: params.BootstrapInstance{
+			Name:   "test-runner",
+			PoolID: "pool-1",
+			OSType: "linux",
+			Flavor: "cx22",
+			Image:  "ubuntu-22.04",
+			OSArch: "amd64",
+		},
+		ControllerID: "controller-xyz",
+	}
+
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{
+		&hcloud.Server{ID: 111111, Name: "other-runner"},
+		&hcloud.Server{ID: 123456, Name: "test-runner"},
+	}, nil)
+
+	server, err := client.CreateInstance(context.Background(), spec)
 	assert.NoError(t, err)
-	assert.Equal(t, serverID, "123456")
+	assert.Equal(t, server.ID, int64(123456))
 	mockAPI.AssertExpectations(t)
+	mockAPI.AssertNotCalled(t, "CreateServer", mock.Anything, mock.Anything)
+}
+
+func TestCreateInstanceLookupError(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI}
+
+	spec := &spec.RunnerSpec{
+		Locations: []string{"fsn1"},
+		BootstrapParams: params.BootstrapInstance{
+			Name:   "test-runner",
+			PoolID: "pool-1",
+		},
+		ControllerID: "controller-xyz",
+	}
+
+	mockAPI.On("GetServersByLabel", mock.Anything, mock.Anything).Return([]*hcloud.Server{}, fmt.Errorf("error while listing instances"))
+
+	server, err := client.CreateInstance(context.Background(), spec)
+	assert.Error(t, err)
+	assert.Nil(t, server)
+	assert.Contains(t, err.Error(), "error while listing instances")
+	mockAPI.AssertNotCalled(t, "CreateServer", mock.Anything, mock.Anything)
 }
 
 func TestDeleteInstance(t *testing.T) {
@@ -182,6 +1280,7 @@ func TestGetInstanceNotFound(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, server)
 	assert.Equal(t, err.Error(), "server with ID \"123456\" not found")
+	assert.ErrorIs(t, err, ErrNotFound)
 	mockAPI.AssertExpectations(t)
 }
 
@@ -200,25 +1299,178 @@ func TestGetInstanceIgnoreNotFound(t *testing.T) {
 	mockAPI.AssertExpectations(t)
 }
 
-func TestGetAllInstances(t *testing.T) {
+func TestCheckInstanceFault(t *testing.T) {
+	longAgo := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		server *hcloud.Server
+		action *hcloud.Action
+		err    error
+		fault  string
+	}{
+		{
+			name:   "running server",
+			server: &hcloud.Server{ID: 123456, Status: hcloud.ServerStatusRunning},
+		},
+		{
+			name:   "stopped server",
+			server: &hcloud.Server{ID: 123456, Status: hcloud.ServerStatusOff, Created: longAgo},
+		},
+		{
+			name:   "failed action",
+			server: &hcloud.Server{ID: 123456, Status: hcloud.ServerStatusInitializing, Created: time.Now()},
+			action: &hcloud.Action{
+				Command:      "create_server",
+				Status:       hcloud.ActionStatusError,
+				ErrorCode:    "server_error",
+				ErrorMessage: "cannot boot server",
+			},
+			fault: "action create_server failed: cannot boot server (server_error)",
+		},
+		{
+			name:   "successful action",
+			server: &hcloud.Server{ID: 123456, Status: hcloud.ServerStatusStopping, Created: longAgo},
+			action: &hcloud.Action{Command: "shutdown_server", Status: hcloud.ActionStatusSuccess, Started: longAgo},
+		},
+		{
+			name:   "action running for too long",
+			server: &hcloud.Server{ID: 123456, Status: hcloud.ServerStatusStarting, Created: longAgo},
+			action: &hcloud.Action{Command: "start_server", Status: hcloud.ActionStatusRunning, Started: longAgo},
+			fault:  "action start_server has been running since " + longAgo.Format(time.RFC3339) + ", server is starting",
+		},
+		{
+			name:   "action still running",
+			server: &hcloud.Server{ID: 123456, Status: hcloud.ServerStatusStarting, Created: longAgo},
+			action: &hcloud.Action{Command: "start_server", Status: hcloud.ActionStatusRunning, Started: time.Now()},
+		},
+		{
+			name:   "initializing for too long",
+			server: &hcloud.Server{ID: 123456, Status: hcloud.ServerStatusInitializing, Created: longAgo},
+			fault:  "server has been initializing since " + longAgo.Format(time.RFC3339),
+		},
+		{
+			name:   "initializing for too long without actions",
+			server: &hcloud.Server{ID: 123456, Status: hcloud.ServerStatusInitializing, Created: longAgo},
+			err:    fmt.Errorf("API error"),
+			fault:  "server has been initializing since " + longAgo.Format(time.RFC3339),
+		},
+		{
+			name:   "initializing",
+			server: &hcloud.Server{ID: 123456, Status: hcloud.ServerStatusInitializing, Created: time.Now()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAPI := new(MockHCloudAPI)
+
+			client := &HcloudClient{api: mockAPI, cfg: &config.Config{StuckTimeout: 10 * time.Minute}}
+
+			mockAPI.On("GetLatestServerAction", mock.Anything, tt.server).Return(tt.action, &hcloud.Response{}, tt.err)
+
+			instance := DeserializeInstance(tt.server)
+			status := instance.Status
+			client.CheckInstanceFault(context.Background(), tt.server, &instance)
+			if tt.server.Status == hcloud.ServerStatusRunning || tt.server.Status == hcloud.ServerStatusOff {
+				mockAPI.AssertNotCalled(t, "GetLatestServerAction", mock.Anything, mock.Anything)
+			}
+			if tt.fault == "" {
+				assert.Equal(t, status, instance.Status)
+				assert.Nil(t, instance.ProviderFault)
+				return
+			}
+			assert.Equal(t, params.InstanceError, instance.Status)
+			assert.Equal(t, tt.fault, string(instance.ProviderFault))
+		})
+	}
+}
+
+func TestGetInstancesByLabels(t *testing.T) {
 	mockAPI := new(MockHCloudAPI)
 
 	client := &HcloudClient{api: mockAPI}
 
-	mockAPI.On("GetAllServers", mock.Anything).Return([]*hcloud.Server{
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz,GARM_POOL_ID=pool-1").Return([]*hcloud.Server{
 		&hcloud.Server{
 			ID:   123456,
 			Name: "my-server",
 		},
 	}, nil)
 
-	servers, err := client.GetAllInstances(context.Background())
+	servers, err := client.GetInstancesByLabels(context.Background(), map[string]string{
+		"GARM_POOL_ID":       "pool-1",
+		"GARM_CONTROLLER_ID": "controller-xyz",
+	})
 
 	assert.NoError(t, err)
+	assert.Len(t, servers, 1)
 	assert.Equal(t, servers[0].ID, int64(123456))
 	mockAPI.AssertExpectations(t)
 }
 
+func TestRemoveAllInstances(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI}
+
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz").Return([]*hcloud.Server{
+		&hcloud.Server{ID: 1, Labels: map[string]string{"GARM_CONTROLLER_ID": "controller-xyz"}},
+		&hcloud.Server{ID: 2, Labels: map[string]string{"GARM_CONTROLLER_ID": "controller-abc"}},
+		&hcloud.Server{ID: 3, Labels: map[string]string{"GARM_CONTROLLER_ID": "controller-xyz"}},
+		&hcloud.Server{ID: 4},
+	}, nil)
+
+	mockAPI.On("DeleteServer", mock.Anything, mock.MatchedBy(func(server *hcloud.Server) bool {
+		return server.ID == 1 || server.ID == 3
+	})).Return(&hcloud.Response{}, nil).Twice()
+
+	err := client.RemoveAllInstances(context.Background(), "controller-xyz")
+	assert.NoError(t, err)
+	mockAPI.AssertExpectations(t)
+	mockAPI.AssertNumberOfCalls(t, "DeleteServer", 2)
+	mockAPI.AssertNotCalled(t, "DeleteServer", mock.Anything, mock.MatchedBy(func(server *hcloud.Server) bool {
+		return server.ID == 2 || server.ID == 4
+	}))
+}
+
+func TestRemoveAllInstancesError(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI}
+
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_CONTROLLER_ID=controller-xyz").Return([]*hcloud.Server{
+		&hcloud.Server{ID: 1, Labels: map[string]string{"GARM_CONTROLLER_ID": "controller-xyz"}},
+		&hcloud.Server{ID: 2, Labels: map[string]string{"GARM_CONTROLLER_ID": "controller-xyz"}},
+		&hcloud.Server{ID: 3, Labels: map[string]string{"GARM_CONTROLLER_ID": "controller-xyz"}},
+	}, nil)
+
+	mockAPI.On("DeleteServer", mock.Anything, mock.MatchedBy(func(server *hcloud.Server) bool {
+		return server.ID == 2
+	})).Return(&hcloud.Response{}, nil)
+	mockAPI.On("DeleteServer", mock.Anything, mock.MatchedBy(func(server *hcloud.Server) bool {
+		return server.ID != 2
+	})).Return(&hcloud.Response{}, fmt.Errorf("server is locked"))
+
+	err := client.RemoveAllInstances(context.Background(), "controller-xyz")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "server is locked (ID: 1)")
+	assert.Contains(t, err.Error(), "server is locked (ID: 3)")
+	assert.NotContains(t, err.Error(), "ID: 2")
+	mockAPI.AssertNumberOfCalls(t, "DeleteServer", 3)
+}
+
+func TestRemoveAllInstancesMissingControllerID(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI}
+
+	err := client.RemoveAllInstances(context.Background(), "")
+	assert.Error(t, err)
+	assert.Equal(t, err.Error(), "missing controller ID")
+	mockAPI.AssertNotCalled(t, "GetServersByLabel", mock.Anything, mock.Anything)
+}
+
 func TestStartInstance(t *testing.T) {
 	mockAPI := new(MockHCloudAPI)
 
@@ -254,18 +1506,57 @@ func TestStartInstanceNotFound(t *testing.T) {
 }
 
 func TestStartInstanceAlreadyStarted(t *testing.T) {
+	for _, status := range []hcloud.ServerStatus{hcloud.ServerStatusRunning, hcloud.ServerStatusStarting} {
+		t.Run(string(status), func(t *testing.T) {
+			mockAPI := new(MockHCloudAPI)
+
+			client := &HcloudClient{api: mockAPI}
+
+			mockAPI.On("GetServer", mock.Anything, mock.MatchedBy(func(instance string) bool {
+				return true
+			})).Return(&hcloud.Server{ID: 123456, Status: status}, &hcloud.Response{}, nil)
+
+			err := client.StartInstance(context.Background(), "123456")
+			assert.NoError(t, err)
+			mockAPI.AssertExpectations(t)
+			mockAPI.AssertNotCalled(t, "StartServer", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestStartInstanceInvalidState(t *testing.T) {
+	for _, status := range []hcloud.ServerStatus{hcloud.ServerStatusDeleting, hcloud.ServerStatusRebuilding} {
+		t.Run(string(status), func(t *testing.T) {
+			mockAPI := new(MockHCloudAPI)
+
+			client := &HcloudClient{api: mockAPI}
+
+			mockAPI.On("GetServer", mock.Anything, "123456").Return(&hcloud.Server{ID: 123456, Status: status}, &hcloud.Response{}, nil)
+
+			err := client.StartInstance(context.Background(), "123456")
+			var stateErr *InvalidStateError
+			assert.ErrorAs(t, err, &stateErr)
+			assert.Equal(t, stateErr.Status, status)
+			assert.EqualError(t, err, "instance 123456 cannot be started in "+string(status)+" state")
+			mockAPI.AssertNotCalled(t, "StartServer", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestStartInstanceQuotaExceeded(t *testing.T) {
 	mockAPI := new(MockHCloudAPI)
 
 	client := &HcloudClient{api: mockAPI}
 
-	mockAPI.On("GetServer", mock.Anything, mock.MatchedBy(func(instance string) bool {
-		return true
-	})).Return(&hcloud.Server{ID: 123456, Status: hcloud.ServerStatusRunning}, &hcloud.Response{}, nil)
+	mockAPI.On("GetServer", mock.Anything, "123456").Return(&hcloud.Server{ID: 123456, Status: hcloud.ServerStatusOff}, &hcloud.Response{}, nil)
+	mockAPI.On("StartServer", mock.Anything, mock.Anything).Return(&hcloud.Action{}, &hcloud.Response{}, hcloud.Error{
+		Code:    hcloud.ErrorCodeResourceLimitExceeded,
+		Message: "core limit exceeded",
+	})
 
 	err := client.StartInstance(context.Background(), "123456")
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "cannot be started in running state")
-	mockAPI.AssertExpectations(t)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.EqualError(t, err, "error while starting: core limit exceeded (resource_limit_exceeded) (ID: 123456)")
 }
 
 func TestStopInstance(t *testing.T) {
@@ -282,22 +1573,98 @@ func TestStopInstance(t *testing.T) {
 		return true
 	})).Return(&hcloud.Action{}, &hcloud.Response{}, nil)
 
-	err := client.StopInstance(context.Background(), "123456")
+	err := client.StopInstance(context.Background(), "123456", true)
 	assert.NoError(t, err)
 	mockAPI.AssertExpectations(t)
 }
 
 func TestStopInstanceAlreadyStopped(t *testing.T) {
+	for _, status := range []hcloud.ServerStatus{hcloud.ServerStatusOff, hcloud.ServerStatusStopping} {
+		t.Run(string(status), func(t *testing.T) {
+			mockAPI := new(MockHCloudAPI)
+
+			client := &HcloudClient{api: mockAPI}
+
+			mockAPI.On("GetServer", mock.Anything, mock.MatchedBy(func(instance string) bool {
+				return true
+			})).Return(&hcloud.Server{ID: 123456, Status: status}, &hcloud.Response{}, nil)
+
+			err := client.StopInstance(context.Background(), "123456", true)
+			assert.NoError(t, err)
+			mockAPI.AssertExpectations(t)
+			mockAPI.AssertNotCalled(t, "StopServer", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestStopInstanceInvalidState(t *testing.T) {
+	for _, status := range []hcloud.ServerStatus{hcloud.ServerStatusDeleting, hcloud.ServerStatusRebuilding} {
+		t.Run(string(status), func(t *testing.T) {
+			mockAPI := new(MockHCloudAPI)
+
+			client := &HcloudClient{api: mockAPI}
+
+			mockAPI.On("GetServer", mock.Anything, "123456").Return(&hcloud.Server{ID: 123456, Status: status}, &hcloud.Response{}, nil)
+
+			err := client.StopInstance(context.Background(), "123456", false)
+			var stateErr *InvalidStateError
+			assert.ErrorAs(t, err, &stateErr)
+			assert.Equal(t, stateErr.Status, status)
+			assert.EqualError(t, err, "instance 123456 cannot be stopped in "+string(status)+" state")
+			mockAPI.AssertNotCalled(t, "ShutdownServer", mock.Anything, mock.Anything)
+			mockAPI.AssertNotCalled(t, "StopServer", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestStopInstanceGraceful(t *testing.T) {
+	shutdownPollInterval = time.Millisecond
+	defer func() { shutdownPollInterval = 5 * time.Second }()
+
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI, cfg: &config.Config{ShutdownTimeout: time.Second}}
+
+	mockAPI.On("GetServer", mock.Anything, "123456").Return(&hcloud.Server{ID: 123456, Status: hcloud.ServerStatusRunning}, &hcloud.Response{}, nil).Once()
+	mockAPI.On("ShutdownServer", mock.Anything, mock.Anything).Return(&hcloud.Action{}, &hcloud.Response{}, nil)
+	mockAPI.On("GetServer", mock.Anything, "123456").Return(&hcloud.Server{ID: 123456, Status: hcloud.ServerStatusStopping}, &hcloud.Response{}, nil).Once()
+	mockAPI.On("GetServer", mock.Anything, "123456").Return(&hcloud.Server{ID: 123456, Status: hcloud.ServerStatusOff}, &hcloud.Response{}, nil).Once()
+
+	err := client.StopInstance(context.Background(), "123456", false)
+	assert.NoError(t, err)
+	mockAPI.AssertExpectations(t)
+	mockAPI.AssertNotCalled(t, "StopServer", mock.Anything, mock.Anything)
+}
+
+func TestStopInstanceGracefulTimeout(t *testing.T) {
+	shutdownPollInterval = time.Millisecond
+	defer func() { shutdownPollInterval = 5 * time.Second }()
+
+	mockAPI := new(MockHCloudAPI)
+
+	client := &HcloudClient{api: mockAPI, cfg: &config.Config{ShutdownTimeout: 20 * time.Millisecond}}
+
+	mockAPI.On("GetServer", mock.Anything, "123456").Return(&hcloud.Server{ID: 123456, Status: hcloud.ServerStatusRunning}, &hcloud.Response{}, nil)
+	mockAPI.On("ShutdownServer", mock.Anything, mock.Anything).Return(&hcloud.Action{}, &hcloud.Response{}, nil)
+	mockAPI.On("StopServer", mock.Anything, mock.MatchedBy(func(server *hcloud.Server) bool {
+		return server.ID == 123456
+	})).Return(&hcloud.Action{}, &hcloud.Response{}, nil)
+
+	err := client.StopInstance(context.Background(), "123456", false)
+	assert.NoError(t, err)
+	mockAPI.AssertExpectations(t)
+}
+
+func TestStopInstanceGracefulError(t *testing.T) {
 	mockAPI := new(MockHCloudAPI)
 
 	client := &HcloudClient{api: mockAPI}
 
-	mockAPI.On("GetServer", mock.Anything, mock.MatchedBy(func(instance string) bool {
-		return true
-	})).Return(&hcloud.Server{ID: 123456, Status: hcloud.ServerStatusOff}, &hcloud.Response{}, nil)
+	mockAPI.On("GetServer", mock.Anything, "123456").Return(&hcloud.Server{ID: 123456, Status: hcloud.ServerStatusRunning}, &hcloud.Response{}, nil)
+	mockAPI.On("ShutdownServer", mock.Anything, mock.Anything).Return(&hcloud.Action{}, &hcloud.Response{}, fmt.Errorf("API error"))
+	mockAPI.On("StopServer", mock.Anything, mock.Anything).Return(&hcloud.Action{}, &hcloud.Response{}, nil)
 
-	err := client.StopInstance(context.Background(), "123456")
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "cannot be stopped in off state")
+	err := client.StopInstance(context.Background(), "123456", false)
+	assert.NoError(t, err)
 	mockAPI.AssertExpectations(t)
 }
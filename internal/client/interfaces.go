@@ -7,20 +7,38 @@ import (
 )
 
 type ClientInterface interface {
-	GetAllServers(ctx context.Context) ([]*hcloud.Server, error)
+	GetServersByLabel(ctx context.Context, labelSelector string) ([]*hcloud.Server, error)
 	GetServer(ctx context.Context, instance string) (*hcloud.Server, *hcloud.Response, error)
 	CreateServer(ctx context.Context, opts hcloud.ServerCreateOpts) (hcloud.ServerCreateResult, *hcloud.Response, error)
 	DeleteServer(ctx context.Context, server *hcloud.Server) (*hcloud.Response, error)
 	StartServer(ctx context.Context, server *hcloud.Server) (*hcloud.Action, *hcloud.Response, error)
 	StopServer(ctx context.Context, server *hcloud.Server) (*hcloud.Action, *hcloud.Response, error)
+	ShutdownServer(ctx context.Context, server *hcloud.Server) (*hcloud.Action, *hcloud.Response, error)
+	WaitForActions(ctx context.Context, actions ...*hcloud.Action) error
+	GetLatestServerAction(ctx context.Context, server *hcloud.Server) (*hcloud.Action, *hcloud.Response, error)
+	GetServerType(ctx context.Context, name string) (*hcloud.ServerType, *hcloud.Response, error)
+	GetImage(ctx context.Context, idOrName string, architecture hcloud.Architecture) (*hcloud.Image, *hcloud.Response, error)
+	GetImageByID(ctx context.Context, id int64) (*hcloud.Image, *hcloud.Response, error)
+	GetImageByName(ctx context.Context, name string, architecture hcloud.Architecture) (*hcloud.Image, *hcloud.Response, error)
+	GetImagesByLabel(ctx context.Context, labelSelector string, architecture hcloud.Architecture) ([]*hcloud.Image, error)
+	GetSSHKey(ctx context.Context, idOrName string) (*hcloud.SSHKey, *hcloud.Response, error)
+	GetSSHKeysByLabel(ctx context.Context, labelSelector string) ([]*hcloud.SSHKey, error)
+	GetNetwork(ctx context.Context, idOrName string) (*hcloud.Network, *hcloud.Response, error)
+	GetNetworksByLabel(ctx context.Context, labelSelector string) ([]*hcloud.Network, error)
+	GetFirewall(ctx context.Context, idOrName string) (*hcloud.Firewall, *hcloud.Response, error)
+	GetFirewallsByLabel(ctx context.Context, labelSelector string) ([]*hcloud.Firewall, error)
+	GetPlacementGroup(ctx context.Context, idOrName string) (*hcloud.PlacementGroup, *hcloud.Response, error)
+	GetPlacementGroupsByLabel(ctx context.Context, labelSelector string) ([]*hcloud.PlacementGroup, error)
 }
 
 type HCloudAPI struct {
 	client *hcloud.Client
 }
 
-func (r *HCloudAPI) GetAllServers(ctx context.Context) ([]*hcloud.Server, error) {
-	return r.client.Server.All(ctx)
+func (r *HCloudAPI) GetServersByLabel(ctx context.Context, labelSelector string) ([]*hcloud.Server, error) {
+	return r.client.Server.AllWithOpts(ctx, hcloud.ServerListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: labelSelector},
+	})
 }
 
 func (r *HCloudAPI) GetServer(ctx context.Context, instance string) (*hcloud.Server, *hcloud.Response, error) {
@@ -44,12 +62,96 @@ func (r *HCloudAPI) StopServer(ctx context.Context, server *hcloud.Server) (*hcl
 	return r.client.Server.Poweroff(ctx, server)
 }
 
+func (r *HCloudAPI) ShutdownServer(ctx context.Context, server *hcloud.Server) (*hcloud.Action, *hcloud.Response, error) {
+	return r.client.Server.Shutdown(ctx, server)
+}
+
+func (r *HCloudAPI) WaitForActions(ctx context.Context, actions ...*hcloud.Action) error {
+	return r.client.Action.WaitFor(ctx, actions...)
+}
+
+func (r *HCloudAPI) GetLatestServerAction(ctx context.Context, server *hcloud.Server) (*hcloud.Action, *hcloud.Response, error) {
+	actions, resp, err := r.client.Server.Action.ListFor(ctx, server, hcloud.ActionListOpts{
+		ListOpts: hcloud.ListOpts{PerPage: 1},
+		Sort:     []string{"started:desc"},
+	})
+	if err != nil || len(actions) == 0 {
+		return nil, resp, err
+	}
+	return actions[0], resp, nil
+}
+
+func (r *HCloudAPI) GetServerType(ctx context.Context, name string) (*hcloud.ServerType, *hcloud.Response, error) {
+	return r.client.ServerType.Get(ctx, name)
+}
+
+func (r *HCloudAPI) GetImage(ctx context.Context, idOrName string, architecture hcloud.Architecture) (*hcloud.Image, *hcloud.Response, error) {
+	return r.client.Image.GetForArchitecture(ctx, idOrName, architecture)
+}
+
+func (r *HCloudAPI) GetImageByID(ctx context.Context, id int64) (*hcloud.Image, *hcloud.Response, error) {
+	return r.client.Image.GetByID(ctx, id)
+}
+
+func (r *HCloudAPI) GetImageByName(ctx context.Context, name string, architecture hcloud.Architecture) (*hcloud.Image, *hcloud.Response, error) {
+	return r.client.Image.GetByNameAndArchitecture(ctx, name, architecture)
+}
+
+func (r *HCloudAPI) GetImagesByLabel(ctx context.Context, labelSelector string, architecture hcloud.Architecture) ([]*hcloud.Image, error) {
+	return r.client.Image.AllWithOpts(ctx, hcloud.ImageListOpts{
+		ListOpts:     hcloud.ListOpts{LabelSelector: labelSelector},
+		Type:         []hcloud.ImageType{hcloud.ImageTypeSnapshot},
+		Status:       []hcloud.ImageStatus{hcloud.ImageStatusAvailable},
+		Architecture: []hcloud.Architecture{architecture},
+	})
+}
+
+func (r *HCloudAPI) GetSSHKey(ctx context.Context, idOrName string) (*hcloud.SSHKey, *hcloud.Response, error) {
+	return r.client.SSHKey.Get(ctx, idOrName)
+}
+
+func (r *HCloudAPI) GetSSHKeysByLabel(ctx context.Context, labelSelector string) ([]*hcloud.SSHKey, error) {
+	return r.client.SSHKey.AllWithOpts(ctx, hcloud.SSHKeyListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: labelSelector},
+	})
+}
+
+func (r *HCloudAPI) GetNetwork(ctx context.Context, idOrName string) (*hcloud.Network, *hcloud.Response, error) {
+	return r.client.Network.Get(ctx, idOrName)
+}
+
+func (r *HCloudAPI) GetNetworksByLabel(ctx context.Context, labelSelector string) ([]*hcloud.Network, error) {
+	return r.client.Network.AllWithOpts(ctx, hcloud.NetworkListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: labelSelector},
+	})
+}
+
+func (r *HCloudAPI) GetFirewall(ctx context.Context, idOrName string) (*hcloud.Firewall, *hcloud.Response, error) {
+	return r.client.Firewall.Get(ctx, idOrName)
+}
+
+func (r *HCloudAPI) GetFirewallsByLabel(ctx context.Context, labelSelector string) ([]*hcloud.Firewall, error) {
+	return r.client.Firewall.AllWithOpts(ctx, hcloud.FirewallListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: labelSelector},
+	})
+}
+
+func (r *HCloudAPI) GetPlacementGroup(ctx context.Context, idOrName string) (*hcloud.PlacementGroup, *hcloud.Response, error) {
+	return r.client.PlacementGroup.Get(ctx, idOrName)
+}
+
+func (r *HCloudAPI) GetPlacementGroupsByLabel(ctx context.Context, labelSelector string) ([]*hcloud.PlacementGroup, error) {
+	return r.client.PlacementGroup.AllWithOpts(ctx, hcloud.PlacementGroupListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: labelSelector},
+	})
+}
+
 type MockHCloudAPI struct {
 	mock.Mock
 }
 
-func (m *MockHCloudAPI) GetAllServers(ctx context.Context) ([]*hcloud.Server, error) {
-	args := m.Called(ctx)
+func (m *MockHCloudAPI) GetServersByLabel(ctx context.Context, labelSelector string) ([]*hcloud.Server, error) {
+	args := m.Called(ctx, labelSelector)
 	return args.Get(0).([]*hcloud.Server), args.Error(1)
 }
 
@@ -81,3 +183,119 @@ func (m *MockHCloudAPI) StopServer(ctx context.Context, server *hcloud.Server) (
 	args := m.Called(ctx, server)
 	return args.Get(0).(*hcloud.Action), args.Get(1).(*hcloud.Response), args.Error(2)
 }
+
+func (m *MockHCloudAPI) ShutdownServer(ctx context.Context, server *hcloud.Server) (*hcloud.Action, *hcloud.Response, error) {
+	args := m.Called(ctx, server)
+	return args.Get(0).(*hcloud.Action), args.Get(1).(*hcloud.Response), args.Error(2)
+}
+
+func (m *MockHCloudAPI) WaitForActions(ctx context.Context, actions ...*hcloud.Action) error {
+	args := m.Called(ctx, actions)
+	return args.Error(0)
+}
+
+func (m *MockHCloudAPI) GetLatestServerAction(ctx context.Context, server *hcloud.Server) (*hcloud.Action, *hcloud.Response, error) {
+	args := m.Called(ctx, server)
+	var action *hcloud.Action
+	if tmp := args.Get(0); tmp != nil {
+		action = tmp.(*hcloud.Action)
+	}
+	return action, args.Get(1).(*hcloud.Response), args.Error(2)
+}
+
+func (m *MockHCloudAPI) GetServerType(ctx context.Context, name string) (*hcloud.ServerType, *hcloud.Response, error) {
+	args := m.Called(ctx, name)
+	var serverType *hcloud.ServerType
+	if tmp := args.Get(0); tmp != nil {
+		serverType = tmp.(*hcloud.ServerType)
+	}
+	return serverType, args.Get(1).(*hcloud.Response), args.Error(2)
+}
+
+func (m *MockHCloudAPI) GetImage(ctx context.Context, idOrName string, architecture hcloud.Architecture) (*hcloud.Image, *hcloud.Response, error) {
+	args := m.Called(ctx, idOrName, architecture)
+	var image *hcloud.Image
+	if tmp := args.Get(0); tmp != nil {
+		image = tmp.(*hcloud.Image)
+	}
+	return image, args.Get(1).(*hcloud.Response), args.Error(2)
+}
+
+func (m *MockHCloudAPI) GetImageByID(ctx context.Context, id int64) (*hcloud.Image, *hcloud.Response, error) {
+	args := m.Called(ctx, id)
+	var image *hcloud.Image
+	if tmp := args.Get(0); tmp != nil {
+		image = tmp.(*hcloud.Image)
+	}
+	return image, args.Get(1).(*hcloud.Response), args.Error(2)
+}
+
+func (m *MockHCloudAPI) GetImageByName(ctx context.Context, name string, architecture hcloud.Architecture) (*hcloud.Image, *hcloud.Response, error) {
+	args := m.Called(ctx, name, architecture)
+	var image *hcloud.Image
+	if tmp := args.Get(0); tmp != nil {
+		image = tmp.(*hcloud.Image)
+	}
+	return image, args.Get(1).(*hcloud.Response), args.Error(2)
+}
+
+func (m *MockHCloudAPI) GetImagesByLabel(ctx context.Context, labelSelector string, architecture hcloud.Architecture) ([]*hcloud.Image, error) {
+	args := m.Called(ctx, labelSelector, architecture)
+	return args.Get(0).([]*hcloud.Image), args.Error(1)
+}
+
+func (m *MockHCloudAPI) GetSSHKey(ctx context.Context, idOrName string) (*hcloud.SSHKey, *hcloud.Response, error) {
+	args := m.Called(ctx, idOrName)
+	var sSHKey *hcloud.SSHKey
+	if tmp := args.Get(0); tmp != nil {
+		sSHKey = tmp.(*hcloud.SSHKey)
+	}
+	return sSHKey, args.Get(1).(*hcloud.Response), args.Error(2)
+}
+
+func (m *MockHCloudAPI) GetSSHKeysByLabel(ctx context.Context, labelSelector string) ([]*hcloud.SSHKey, error) {
+	args := m.Called(ctx, labelSelector)
+	return args.Get(0).([]*hcloud.SSHKey), args.Error(1)
+}
+
+func (m *MockHCloudAPI) GetNetwork(ctx context.Context, idOrName string) (*hcloud.Network, *hcloud.Response, error) {
+	args := m.Called(ctx, idOrName)
+	var network *hcloud.Network
+	if tmp := args.Get(0); tmp != nil {
+		network = tmp.(*hcloud.Network)
+	}
+	return network, args.Get(1).(*hcloud.Response), args.Error(2)
+}
+
+func (m *MockHCloudAPI) GetNetworksByLabel(ctx context.Context, labelSelector string) ([]*hcloud.Network, error) {
+	args := m.Called(ctx, labelSelector)
+	return args.Get(0).([]*hcloud.Network), args.Error(1)
+}
+
+func (m *MockHCloudAPI) GetFirewall(ctx context.Context, idOrName string) (*hcloud.Firewall, *hcloud.Response, error) {
+	args := m.Called(ctx, idOrName)
+	var firewall *hcloud.Firewall
+	if tmp := args.Get(0); tmp != nil {
+		firewall = tmp.(*hcloud.Firewall)
+	}
+	return firewall, args.Get(1).(*hcloud.Response), args.Error(2)
+}
+
+func (m *MockHCloudAPI) GetFirewallsByLabel(ctx context.Context, labelSelector string) ([]*hcloud.Firewall, error) {
+	args := m.Called(ctx, labelSelector)
+	return args.Get(0).([]*hcloud.Firewall), args.Error(1)
+}
+
+func (m *MockHCloudAPI) GetPlacementGroup(ctx context.Context, idOrName string) (*hcloud.PlacementGroup, *hcloud.Response, error) {
+	args := m.Called(ctx, idOrName)
+	var placementGroup *hcloud.PlacementGroup
+	if tmp := args.Get(0); tmp != nil {
+		placementGroup = tmp.(*hcloud.PlacementGroup)
+	}
+	return placementGroup, args.Get(1).(*hcloud.Response), args.Error(2)
+}
+
+func (m *MockHCloudAPI) GetPlacementGroupsByLabel(ctx context.Context, labelSelector string) ([]*hcloud.PlacementGroup, error) {
+	args := m.Called(ctx, labelSelector)
+	return args.Get(0).([]*hcloud.PlacementGroup), args.Error(1)
+}
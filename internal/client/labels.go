@@ -0,0 +1,58 @@
+package client
+
+import (
+	"encoding/base32"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxLabelValueLength = 63
+	encodedLabelPrefix  = "enc."
+)
+
+var (
+	labelValueRegexp   = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9._-]*[a-zA-Z0-9])?)?$`)
+	labelValueEncoding = base32.NewEncoding("0123456789abcdefghijklmnopqrstuv").WithPadding(base32.NoPadding)
+)
+
+// encodeLabelValue returns value unchanged when Hetzner accepts it as a label
+// value. Other values are base32 encoded behind the "enc." prefix, so they
+// can be decoded back by decodeLabelValue.
+func encodeLabelValue(value string) (string, error) {
+	if len(value) <= maxLabelValueLength && labelValueRegexp.MatchString(value) && !strings.HasPrefix(value, encodedLabelPrefix) {
+		return value, nil
+	}
+	encoded := encodedLabelPrefix + labelValueEncoding.EncodeToString([]byte(value))
+	if len(encoded) > maxLabelValueLength {
+		return "", fmt.Errorf("label value %q is too long to be encoded in %d characters", value, maxLabelValueLength)
+	}
+	return encoded, nil
+}
+
+// decodeLabelValue reverses encodeLabelValue. Values which were not encoded
+// are returned unchanged.
+func decodeLabelValue(value string) string {
+	encoded, ok := strings.CutPrefix(value, encodedLabelPrefix)
+	if !ok {
+		return value
+	}
+	decoded, err := labelValueEncoding.DecodeString(encoded)
+	if err != nil {
+		return value
+	}
+	return string(decoded)
+}
+
+func encodeLabels(labels map[string]string) (map[string]string, error) {
+	encoded := make(map[string]string, len(labels))
+	for key, value := range labels {
+		encodedValue, err := encodeLabelValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for label %q: %w", key, err)
+		}
+		encoded[key] = encodedValue
+	}
+	return encoded, nil
+}
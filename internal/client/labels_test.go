@@ -0,0 +1,87 @@
+package client
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestEncodeLabelValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		encoded   string
+		errString string
+	}{
+		{
+			name:    "valid value",
+			value:   "garm-runner-1",
+			encoded: "garm-runner-1",
+		},
+		{
+			name:    "empty value",
+			value:   "",
+			encoded: "",
+		},
+		{
+			name:    "invalid characters",
+			value:   "runner name",
+			encoded: "enc.e9qmsrj5e8g6sobdck",
+		},
+		{
+			name:    "value with the encoding prefix",
+			value:   "enc.runner",
+			encoded: "enc.cln66bjieln6spbi",
+		},
+		{
+			name:      "value longer than 63 characters",
+			value:     strings.Repeat("a", 37) + "!",
+			encoded:   "",
+			errString: "is too long to be encoded in 63 characters",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := encodeLabelValue(tt.value)
+			if tt.errString != "" {
+				assert.ErrorContains(t, err, tt.errString)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.encoded, encoded)
+			assert.LessOrEqual(t, len(encoded), maxLabelValueLength)
+			assert.Regexp(t, labelValueRegexp, encoded)
+			assert.Equal(t, tt.value, decodeLabelValue(encoded))
+		})
+	}
+}
+
+func TestEncodeLongLabelValue(t *testing.T) {
+	value := strings.Repeat("a", 64)
+	encoded, err := encodeLabelValue(value)
+	assert.Error(t, err)
+	assert.Empty(t, encoded)
+
+	value = strings.Repeat("é", 18)
+	encoded, err = encodeLabelValue(value)
+	assert.NoError(t, err)
+	assert.Equal(t, value, decodeLabelValue(encoded))
+}
+
+func TestDecodeLabelValueInvalid(t *testing.T) {
+	assert.Equal(t, "enc.!", decodeLabelValue("enc.!"))
+	assert.Equal(t, "runner", decodeLabelValue("runner"))
+}
+
+func TestEncodeLabels(t *testing.T) {
+	labels, err := encodeLabels(map[string]string{
+		"Name":         "pool/runner 1",
+		"GARM_POOL_ID": "pool-1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "pool-1", labels["GARM_POOL_ID"])
+	assert.Equal(t, "pool/runner 1", decodeLabelValue(labels["Name"]))
+
+	_, err = encodeLabels(map[string]string{"Name": strings.Repeat("a", 64)})
+	assert.ErrorContains(t, err, "invalid value for label \"Name\"")
+}
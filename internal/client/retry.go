@@ -0,0 +1,317 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+const defaultRetryBaseDelay = 500 * time.Millisecond
+
+// RetryAPI wraps a ClientInterface and retries calls failing with rate limits
+// or transient errors, using a jittered exponential backoff.
+type RetryAPI struct {
+	api         ClientInterface
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+func NewRetryAPI(api ClientInterface, maxAttempts int, maxDelay time.Duration) *RetryAPI {
+	return &RetryAPI{
+		api:         api,
+		maxAttempts: maxAttempts,
+		baseDelay:   defaultRetryBaseDelay,
+		maxDelay:    maxDelay,
+	}
+}
+
+func (r *RetryAPI) GetServersByLabel(ctx context.Context, labelSelector string) ([]*hcloud.Server, error) {
+	var servers []*hcloud.Server
+	_, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		servers, err = r.api.GetServersByLabel(ctx, labelSelector)
+		return nil, err
+	})
+	return servers, err
+}
+
+func (r *RetryAPI) GetServer(ctx context.Context, instance string) (*hcloud.Server, *hcloud.Response, error) {
+	var server *hcloud.Server
+	resp, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		server, resp, err = r.api.GetServer(ctx, instance)
+		return resp, err
+	})
+	return server, resp, err
+}
+
+func (r *RetryAPI) CreateServer(ctx context.Context, opts hcloud.ServerCreateOpts) (hcloud.ServerCreateResult, *hcloud.Response, error) {
+	var result hcloud.ServerCreateResult
+	resp, err := r.do(ctx, false, func() (resp *hcloud.Response, err error) {
+		result, resp, err = r.api.CreateServer(ctx, opts)
+		return resp, err
+	})
+	return result, resp, err
+}
+
+func (r *RetryAPI) DeleteServer(ctx context.Context, server *hcloud.Server) (*hcloud.Response, error) {
+	return r.do(ctx, true, func() (*hcloud.Response, error) {
+		return r.api.DeleteServer(ctx, server)
+	})
+}
+
+func (r *RetryAPI) StartServer(ctx context.Context, server *hcloud.Server) (*hcloud.Action, *hcloud.Response, error) {
+	var action *hcloud.Action
+	resp, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		action, resp, err = r.api.StartServer(ctx, server)
+		return resp, err
+	})
+	return action, resp, err
+}
+
+func (r *RetryAPI) StopServer(ctx context.Context, server *hcloud.Server) (*hcloud.Action, *hcloud.Response, error) {
+	var action *hcloud.Action
+	resp, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		action, resp, err = r.api.StopServer(ctx, server)
+		return resp, err
+	})
+	return action, resp, err
+}
+
+func (r *RetryAPI) ShutdownServer(ctx context.Context, server *hcloud.Server) (*hcloud.Action, *hcloud.Response, error) {
+	var action *hcloud.Action
+	resp, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		action, resp, err = r.api.ShutdownServer(ctx, server)
+		return resp, err
+	})
+	return action, resp, err
+}
+
+func (r *RetryAPI) WaitForActions(ctx context.Context, actions ...*hcloud.Action) error {
+	_, err := r.do(ctx, true, func() (*hcloud.Response, error) {
+		return nil, r.api.WaitForActions(ctx, actions...)
+	})
+	return err
+}
+
+func (r *RetryAPI) GetLatestServerAction(ctx context.Context, server *hcloud.Server) (*hcloud.Action, *hcloud.Response, error) {
+	var action *hcloud.Action
+	resp, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		action, resp, err = r.api.GetLatestServerAction(ctx, server)
+		return resp, err
+	})
+	return action, resp, err
+}
+
+func (r *RetryAPI) GetServerType(ctx context.Context, name string) (*hcloud.ServerType, *hcloud.Response, error) {
+	var serverType *hcloud.ServerType
+	resp, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		serverType, resp, err = r.api.GetServerType(ctx, name)
+		return resp, err
+	})
+	return serverType, resp, err
+}
+
+func (r *RetryAPI) GetImage(ctx context.Context, idOrName string, architecture hcloud.Architecture) (*hcloud.Image, *hcloud.Response, error) {
+	var image *hcloud.Image
+	resp, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		image, resp, err = r.api.GetImage(ctx, idOrName, architecture)
+		return resp, err
+	})
+	return image, resp, err
+}
+
+func (r *RetryAPI) GetImageByID(ctx context.Context, id int64) (*hcloud.Image, *hcloud.Response, error) {
+	var image *hcloud.Image
+	resp, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		image, resp, err = r.api.GetImageByID(ctx, id)
+		return resp, err
+	})
+	return image, resp, err
+}
+
+func (r *RetryAPI) GetImageByName(ctx context.Context, name string, architecture hcloud.Architecture) (*hcloud.Image, *hcloud.Response, error) {
+	var image *hcloud.Image
+	resp, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		image, resp, err = r.api.GetImageByName(ctx, name, architecture)
+		return resp, err
+	})
+	return image, resp, err
+}
+
+func (r *RetryAPI) GetImagesByLabel(ctx context.Context, labelSelector string, architecture hcloud.Architecture) ([]*hcloud.Image, error) {
+	var images []*hcloud.Image
+	_, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		images, err = r.api.GetImagesByLabel(ctx, labelSelector, architecture)
+		return nil, err
+	})
+	return images, err
+}
+
+func (r *RetryAPI) GetSSHKey(ctx context.Context, idOrName string) (*hcloud.SSHKey, *hcloud.Response, error) {
+	var sSHKey *hcloud.SSHKey
+	resp, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		sSHKey, resp, err = r.api.GetSSHKey(ctx, idOrName)
+		return resp, err
+	})
+	return sSHKey, resp, err
+}
+
+func (r *RetryAPI) GetSSHKeysByLabel(ctx context.Context, labelSelector string) ([]*hcloud.SSHKey, error) {
+	var sSHKeys []*hcloud.SSHKey
+	_, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		sSHKeys, err = r.api.GetSSHKeysByLabel(ctx, labelSelector)
+		return nil, err
+	})
+	return sSHKeys, err
+}
+
+func (r *RetryAPI) GetNetwork(ctx context.Context, idOrName string) (*hcloud.Network, *hcloud.Response, error) {
+	var network *hcloud.Network
+	resp, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		network, resp, err = r.api.GetNetwork(ctx, idOrName)
+		return resp, err
+	})
+	return network, resp, err
+}
+
+func (r *RetryAPI) GetNetworksByLabel(ctx context.Context, labelSelector string) ([]*hcloud.Network, error) {
+	var networks []*hcloud.Network
+	_, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		networks, err = r.api.GetNetworksByLabel(ctx, labelSelector)
+		return nil, err
+	})
+	return networks, err
+}
+
+func (r *RetryAPI) GetFirewall(ctx context.Context, idOrName string) (*hcloud.Firewall, *hcloud.Response, error) {
+	var firewall *hcloud.Firewall
+	resp, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		firewall, resp, err = r.api.GetFirewall(ctx, idOrName)
+		return resp, err
+	})
+	return firewall, resp, err
+}
+
+func (r *RetryAPI) GetFirewallsByLabel(ctx context.Context, labelSelector string) ([]*hcloud.Firewall, error) {
+	var firewalls []*hcloud.Firewall
+	_, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		firewalls, err = r.api.GetFirewallsByLabel(ctx, labelSelector)
+		return nil, err
+	})
+	return firewalls, err
+}
+
+func (r *RetryAPI) GetPlacementGroup(ctx context.Context, idOrName string) (*hcloud.PlacementGroup, *hcloud.Response, error) {
+	var placementGroup *hcloud.PlacementGroup
+	resp, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		placementGroup, resp, err = r.api.GetPlacementGroup(ctx, idOrName)
+		return resp, err
+	})
+	return placementGroup, resp, err
+}
+
+func (r *RetryAPI) GetPlacementGroupsByLabel(ctx context.Context, labelSelector string) ([]*hcloud.PlacementGroup, error) {
+	var placementGroups []*hcloud.PlacementGroup
+	_, err := r.do(ctx, true, func() (resp *hcloud.Response, err error) {
+		placementGroups, err = r.api.GetPlacementGroupsByLabel(ctx, labelSelector)
+		return nil, err
+	})
+	return placementGroups, err
+}
+
+// do calls fn until it succeeds, fails with a non retryable error or the
+// maximum number of attempts is reached. Non idempotent calls are only retried
+// when the API explicitly rejected the request.
+func (r *RetryAPI) do(ctx context.Context, idempotent bool, fn func() (*hcloud.Response, error)) (*hcloud.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := fn()
+		if err == nil || ctx.Err() != nil || attempt >= r.maxAttempts || !isRetryable(resp, err, idempotent) {
+			return resp, err
+		}
+		select {
+		case <-ctx.Done():
+			return resp, err
+		case <-time.After(r.backoff(attempt, resp, err)):
+		}
+	}
+}
+
+func (r *RetryAPI) backoff(attempt int, resp *hcloud.Response, err error) time.Duration {
+	if reset := rateLimitReset(resp, err); !reset.IsZero() {
+		if delay := time.Until(reset); delay > 0 {
+			return min(delay, r.maxDelay)
+		}
+	}
+	delay := r.maxDelay
+	if shift := attempt - 1; shift < 32 {
+		delay = min(r.baseDelay<<shift, r.maxDelay)
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func isRetryable(resp *hcloud.Response, err error, idempotent bool) bool {
+	var apiErr hcloud.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code { //nolint:exhaustive
+		case hcloud.ErrorCodeRateLimitExceeded,
+			hcloud.ErrorCodeConflict,
+			hcloud.ErrorCodeLocked,
+			hcloud.ErrorCodeMaintenance,
+			hcloud.ErrorCodeRobotUnavailable:
+			return true
+		case hcloud.ErrorCodeBadGateway,
+			hcloud.ErrorCodeTimeout,
+			hcloud.ErrorCodeServerError,
+			hcloud.ErrorCodeServiceError:
+			return idempotent
+		}
+		if apiErr.Response() != nil {
+			resp = apiErr.Response()
+		}
+	}
+
+	if resp != nil && resp.Response != nil {
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return true
+		case http.StatusBadGateway, http.StatusGatewayTimeout:
+			return idempotent
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return idempotent
+	}
+	return false
+}
+
+func rateLimitReset(resp *hcloud.Response, err error) time.Time {
+	var apiErr hcloud.Error
+	if errors.As(err, &apiErr) {
+		if apiErr.Code != hcloud.ErrorCodeRateLimitExceeded {
+			return time.Time{}
+		}
+		if apiErr.Response() != nil {
+			resp = apiErr.Response()
+		}
+	} else if resp == nil || resp.Response == nil || resp.StatusCode != http.StatusTooManyRequests {
+		return time.Time{}
+	}
+	if resp == nil {
+		return time.Time{}
+	}
+	return resp.Meta.Ratelimit.Reset
+}
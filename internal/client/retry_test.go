@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestRetryAPI(api ClientInterface, maxAttempts int) *RetryAPI {
+	return &RetryAPI{
+		api:         api,
+		maxAttempts: maxAttempts,
+		baseDelay:   time.Millisecond,
+		maxDelay:    10 * time.Millisecond,
+	}
+}
+
+func TestRetryRateLimited(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+	api := newTestRetryAPI(mockAPI, 3)
+
+	mockAPI.On("GetServer", mock.Anything, "123456").Return(nil, &hcloud.Response{}, hcloud.Error{
+		Code:    hcloud.ErrorCodeRateLimitExceeded,
+		Message: "limit of 3600 requests per hour reached",
+	}).Once()
+	mockAPI.On("GetServer", mock.Anything, "123456").Return(&hcloud.Server{ID: 123456}, &hcloud.Response{}, nil).Once()
+
+	server, _, err := api.GetServer(context.Background(), "123456")
+	assert.NoError(t, err)
+	assert.Equal(t, server.ID, int64(123456))
+	mockAPI.AssertExpectations(t)
+}
+
+func TestRetryServiceUnavailable(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+	api := newTestRetryAPI(mockAPI, 3)
+
+	server := &hcloud.Server{ID: 123456}
+	mockAPI.On("DeleteServer", mock.Anything, server).Return(&hcloud.Response{
+		Response: &http.Response{StatusCode: http.StatusServiceUnavailable},
+	}, fmt.Errorf("server responded with status code 503")).Once()
+	mockAPI.On("DeleteServer", mock.Anything, server).Return(&hcloud.Response{}, nil).Once()
+
+	_, err := api.DeleteServer(context.Background(), server)
+	assert.NoError(t, err)
+	mockAPI.AssertExpectations(t)
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+	api := newTestRetryAPI(mockAPI, 3)
+
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, io.ErrUnexpectedEOF)
+
+	_, err := api.GetServersByLabel(context.Background(), "GARM_POOL_ID=pool-1")
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	mockAPI.AssertNumberOfCalls(t, "GetServersByLabel", 3)
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+	api := newTestRetryAPI(mockAPI, 3)
+
+	mockAPI.On("GetServersByLabel", mock.Anything, "GARM_POOL_ID=pool-1").Return([]*hcloud.Server{}, hcloud.Error{
+		Code:    hcloud.ErrorCodeInvalidInput,
+		Message: "invalid label selector",
+	})
+
+	_, err := api.GetServersByLabel(context.Background(), "GARM_POOL_ID=pool-1")
+	assert.Error(t, err)
+	mockAPI.AssertNumberOfCalls(t, "GetServersByLabel", 1)
+}
+
+func TestRetryCreateServerNotIdempotent(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+	api := newTestRetryAPI(mockAPI, 3)
+
+	mockAPI.On("CreateServer", mock.Anything, mock.Anything).Return(hcloud.ServerCreateResult{}, &hcloud.Response{}, io.ErrUnexpectedEOF)
+
+	_, _, err := api.CreateServer(context.Background(), hcloud.ServerCreateOpts{})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	mockAPI.AssertNumberOfCalls(t, "CreateServer", 1)
+}
+
+func TestRetryCreateServerRateLimited(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+	api := newTestRetryAPI(mockAPI, 3)
+
+	mockAPI.On("CreateServer", mock.Anything, mock.Anything).Return(hcloud.ServerCreateResult{}, &hcloud.Response{}, hcloud.Error{
+		Code: hcloud.ErrorCodeRateLimitExceeded,
+	}).Once()
+	mockAPI.On("CreateServer", mock.Anything, mock.Anything).Return(hcloud.ServerCreateResult{
+		Server: &hcloud.Server{ID: 123456},
+	}, &hcloud.Response{}, nil).Once()
+
+	result, _, err := api.CreateServer(context.Background(), hcloud.ServerCreateOpts{})
+	assert.NoError(t, err)
+	assert.Equal(t, result.Server.ID, int64(123456))
+	mockAPI.AssertExpectations(t)
+}
+
+func TestRetryContextCanceled(t *testing.T) {
+	mockAPI := new(MockHCloudAPI)
+	api := newTestRetryAPI(mockAPI, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mockAPI.On("StartServer", mock.Anything, mock.Anything).Return(&hcloud.Action{}, &hcloud.Response{}, hcloud.Error{
+		Code: hcloud.ErrorCodeLocked,
+	})
+
+	_, _, err := api.StartServer(ctx, &hcloud.Server{ID: 123456})
+	assert.Error(t, err)
+	mockAPI.AssertNumberOfCalls(t, "StartServer", 1)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	api := &RetryAPI{
+		baseDelay: time.Second,
+		maxDelay:  10 * time.Second,
+	}
+
+	for attempt := 1; attempt < 100; attempt++ {
+		delay := api.backoff(attempt, nil, fmt.Errorf("error"))
+		assert.LessOrEqual(t, delay, 10*time.Second)
+		assert.Greater(t, delay, time.Duration(0))
+	}
+	delay := api.backoff(1, nil, fmt.Errorf("error"))
+	assert.GreaterOrEqual(t, delay, 500*time.Millisecond)
+	assert.LessOrEqual(t, delay, time.Second)
+}
+
+func TestRetryBackoffRateLimitReset(t *testing.T) {
+	api := &RetryAPI{
+		baseDelay: time.Millisecond,
+		maxDelay:  time.Minute,
+	}
+	resp := &hcloud.Response{
+		Response: &http.Response{StatusCode: http.StatusTooManyRequests},
+		Meta: hcloud.Meta{
+			Ratelimit: hcloud.Ratelimit{Reset: time.Now().Add(20 * time.Second)},
+		},
+	}
+
+	delay := api.backoff(1, resp, fmt.Errorf("server responded with status code 429"))
+	assert.Greater(t, delay, 15*time.Second)
+	assert.LessOrEqual(t, delay, 20*time.Second)
+
+	api.maxDelay = 5 * time.Second
+	delay = api.backoff(1, resp, fmt.Errorf("server responded with status code 429"))
+	assert.Equal(t, delay, 5*time.Second)
+}
@@ -4,6 +4,10 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/cloudbase/garm-provider-common/cloudconfig"
 	"github.com/cloudbase/garm-provider-common/params"
@@ -41,33 +45,186 @@ func jsonSchemaValidation(schema json.RawMessage) error {
 }
 
 func newExtraSpecsFromBootstrapData(data params.BootstrapInstance) (*extraSpecs, error) {
+	return parseExtraSpecs(data.ExtraSpecs)
+}
+
+// newExtraSpecsFromDefaults loads the extra specs of the defaults table of the
+// provider config.
+func newExtraSpecsFromDefaults(cfg *config.Config) (*extraSpecs, error) {
+	if len(cfg.Defaults) == 0 {
+		return &extraSpecs{}, nil
+	}
+	data, err := json.Marshal(cfg.Defaults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal defaults: %w", err)
+	}
+	spec, err := parseExtraSpecs(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveProfiles(cfg, spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// ValidateDefaults checks the defaults table of the provider config against
+// the extra specs schema.
+func ValidateDefaults(cfg *config.Config) error {
+	if _, err := newExtraSpecsFromDefaults(cfg); err != nil {
+		return fmt.Errorf("invalid defaults: %w", err)
+	}
+	return nil
+}
+
+func newExtraSpecsFromProfile(name string, profile map[string]any) (*extraSpecs, error) {
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile %q: failed to marshal profile: %w", name, err)
+	}
+	spec, err := parseExtraSpecs(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile %q: %w", name, err)
+	}
+	if spec.Profile != nil {
+		return nil, fmt.Errorf("invalid profile %q: profiles can't reference other profiles", name)
+	}
+	return spec, nil
+}
+
+// ValidateProfiles checks the profiles of the provider config against the
+// extra specs schema.
+func ValidateProfiles(cfg *config.Config) error {
+	for _, name := range slices.Sorted(maps.Keys(cfg.Profiles)) {
+		if _, err := newExtraSpecsFromProfile(name, cfg.Profiles[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveProfiles loads the profiles referenced by spec, so MergeExtraSpecs
+// can apply them before the values of spec.
+func resolveProfiles(cfg *config.Config, spec *extraSpecs) error {
+	for _, name := range spec.Profile {
+		profile, ok := cfg.Profiles[name]
+		if !ok {
+			return fmt.Errorf("unknown profile %q", name)
+		}
+		profileSpec, err := newExtraSpecsFromProfile(name, profile)
+		if err != nil {
+			return err
+		}
+		spec.profiles = append(spec.profiles, profileSpec)
+	}
+	return nil
+}
+
+func parseExtraSpecs(data json.RawMessage) (*extraSpecs, error) {
 	spec := &extraSpecs{}
 
-	if err := jsonSchemaValidation(data.ExtraSpecs); err != nil {
+	if err := jsonSchemaValidation(data); err != nil {
 		return nil, fmt.Errorf("failed to validate extra specs: %w", err)
 	}
 
-	if len(data.ExtraSpecs) > 0 {
-		if err := json.Unmarshal(data.ExtraSpecs, spec); err != nil {
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, spec); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal extra specs: %w", err)
 		}
 	}
 
+	if err := config.ValidateLabels(spec.Labels); err != nil {
+		return nil, fmt.Errorf("invalid labels: %w", err)
+	}
+
 	return spec, nil
 }
 
+const labelSelectorPrefix = "label:"
+
+// ResourceRef references a Hetzner resource either by ID, by name, or by
+// label selector when prefixed with "label:".
+type ResourceRef string
+
+func (r *ResourceRef) UnmarshalJSON(data []byte) error {
+	var id int64
+	if err := json.Unmarshal(data, &id); err == nil {
+		*r = ResourceRef(strconv.FormatInt(id, 10))
+		return nil
+	}
+	var ref string
+	if err := json.Unmarshal(data, &ref); err != nil {
+		return fmt.Errorf("invalid resource reference: %w", err)
+	}
+	*r = ResourceRef(ref)
+	return nil
+}
+
+func (ResourceRef) JSONSchema() *jsonschema.Schema {
+	minLength := uint64(1)
+	return &jsonschema.Schema{
+		OneOf: []*jsonschema.Schema{
+			{Type: "integer"},
+			{Type: "string", MinLength: &minLength},
+		},
+	}
+}
+
+func (r ResourceRef) ID() (int64, bool) {
+	id, err := strconv.ParseInt(string(r), 10, 64)
+	return id, err == nil
+}
+
+func (r ResourceRef) LabelSelector() (string, bool) {
+	return strings.CutPrefix(string(r), labelSelectorPrefix)
+}
+
+// ProfileNames is an ordered list of profiles of the provider config. It can
+// be decoded from either a single profile name or a list of names.
+type ProfileNames []string
+
+func (p *ProfileNames) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*p = ProfileNames{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("invalid profile: %w", err)
+	}
+	*p = names
+	return nil
+}
+
+func (ProfileNames) JSONSchema() *jsonschema.Schema {
+	minLength := uint64(1)
+	return &jsonschema.Schema{
+		OneOf: []*jsonschema.Schema{
+			{Type: "string", MinLength: &minLength},
+			{Type: "array", Items: &jsonschema.Schema{Type: "string", MinLength: &minLength}},
+		},
+	}
+}
+
 type extraSpecs struct {
-	Location        *string  `json:"location,omitempty" jsonschema:"description=Location where to create the server."`
-	SSHKeys         []int64  `json:"ssh_keys,omitempty" jsonschema:"description=ID of SSH keys to use for the instance."`
-	PlacementGroup  *int64   `json:"placement_group,omitempty" jsonschema:"description=ID of the placement Group where the Server should be in."`
-	Networks        []int64  `json:"networks,omitempty" jsonschema:"description=Network IDs which should be attached to the Server private network interface."`
-	Firewalls       []int64  `json:"firewalls,omitempty" jsonschema:"description=Firewall IDs which should be applied on the Server's public network interface."`
-	DisableUpdates  *bool    `json:"disable_updates,omitempty" jsonschema:"description=Disable automatic updates on the VM."`
-	EnableBootDebug *bool    `json:"enable_boot_debug,omitempty" jsonschema:"description=Enable boot debug on the VM."`
-	DisableIPv4     *bool    `json:"disable_ipv4,omitempty" jsonschema:"description=Disable public IPv4."`
-	DisableIPv6     *bool    `json:"disable_ipv6,omitempty" jsonschema:"description=Disable public IPv6."`
-	ExtraPackages   []string `json:"extra_packages,omitempty" jsonschema:"description=Extra packages to install on the VM."`
+	Profile             ProfileNames      `json:"profile,omitempty" jsonschema:"description=Profile of the provider configuration to apply, or ordered list of profiles applied in turn. Other extra specs override the profiles."`
+	Location            config.Locations  `json:"location,omitempty" jsonschema:"description=Location where to create the server, or ordered list of locations to try."`
+	SSHKeys             []ResourceRef     `json:"ssh_keys,omitempty" jsonschema:"description=ID, name or label selector of SSH keys to use for the instance."`
+	PlacementGroup      *ResourceRef      `json:"placement_group,omitempty" jsonschema:"description=ID, name or label selector of the placement Group where the Server should be in."`
+	Networks            []ResourceRef     `json:"networks,omitempty" jsonschema:"description=Network IDs, names or label selectors which should be attached to the Server private network interface."`
+	Firewalls           []ResourceRef     `json:"firewalls,omitempty" jsonschema:"description=Firewall IDs, names or label selectors which should be applied on the Server's public network interface."`
+	DisableUpdates      *bool             `json:"disable_updates,omitempty" jsonschema:"description=Disable automatic updates on the VM."`
+	EnableBootDebug     *bool             `json:"enable_boot_debug,omitempty" jsonschema:"description=Enable boot debug on the VM."`
+	DisableIPv4         *bool             `json:"disable_ipv4,omitempty" jsonschema:"description=Disable public IPv4."`
+	DisableIPv6         *bool             `json:"disable_ipv6,omitempty" jsonschema:"description=Disable public IPv6."`
+	ExtraPackages       []string          `json:"extra_packages,omitempty" jsonschema:"description=Extra packages to install on the VM."`
+	ServerTypeFallbacks []string          `json:"server_type_fallbacks,omitempty" jsonschema:"description=Server types to try in order when the pool flavor is unavailable or deprecated."`
+	Labels              map[string]string `json:"labels,omitempty" jsonschema:"description=Labels to add to the server, merged with the labels of the provider configuration."`
+	Project             *string           `json:"project,omitempty" jsonschema:"description=Project of the provider configuration where to create the server. An empty name selects the default project."`
 	cloudconfig.CloudConfigSpec
+
+	profiles []*extraSpecs
 }
 
 func GetRunnerSpecFromBootstrapParams(cfg *config.Config, data params.BootstrapInstance, controllerID string) (*RunnerSpec, error) {
@@ -76,19 +233,40 @@ func GetRunnerSpecFromBootstrapParams(cfg *config.Config, data params.BootstrapI
 		return nil, fmt.Errorf("failed to get tools: %s", err)
 	}
 
+	defaults, err := newExtraSpecsFromDefaults(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error loading defaults: %w", err)
+	}
+
 	extraSpecs, err := newExtraSpecsFromBootstrapData(data)
 	if err != nil {
 		return nil, fmt.Errorf("error loading extra specs: %w", err)
 	}
+	if err := resolveProfiles(cfg, extraSpecs); err != nil {
+		return nil, fmt.Errorf("error loading extra specs: %w", err)
+	}
+
+	// The project can be selected by any extra spec, but its location only
+	// overrides the defaults, not the profiles and values of the pool.
+	selected := &RunnerSpec{}
+	selected.MergeExtraSpecs(defaults)
+	selected.MergeExtraSpecs(extraSpecs)
+	if _, err := cfg.ProjectConfig(selected.Project); err != nil {
+		return nil, fmt.Errorf("error loading extra specs: %w", err)
+	}
 
 	spec := &RunnerSpec{
-		Location:        cfg.Location,
-		ExtraPackages:   extraSpecs.ExtraPackages,
+		Locations:       cfg.Location,
+		Labels:          maps.Clone(cfg.Labels),
 		Tools:           tools,
 		BootstrapParams: data,
 		ControllerID:    controllerID,
 	}
 
+	spec.MergeExtraSpecs(defaults)
+	if project := cfg.Projects[selected.Project]; len(project.Location) > 0 {
+		spec.Locations = project.Location
+	}
 	spec.MergeExtraSpecs(extraSpecs)
 
 	if err := spec.Validate(); err != nil {
@@ -99,23 +277,27 @@ func GetRunnerSpecFromBootstrapParams(cfg *config.Config, data params.BootstrapI
 }
 
 type RunnerSpec struct {
-	Location        string
-	DisableUpdates  bool
-	ExtraPackages   []string
-	EnableBootDebug bool
-	Tools           params.RunnerApplicationDownload
-	BootstrapParams params.BootstrapInstance
-	SSHKeys         []int64
-	PlacementGroup  int64
-	Networks        []int64
-	Firewalls       []int64
-	DisableIPv4     bool
-	DisableIPv6     bool
-	ControllerID    string
+	Locations           []string
+	DisableUpdates      bool
+	ExtraPackages       []string
+	EnableBootDebug     bool
+	Tools               params.RunnerApplicationDownload
+	BootstrapParams     params.BootstrapInstance
+	SSHKeys             []ResourceRef
+	PlacementGroup      ResourceRef
+	Networks            []ResourceRef
+	Firewalls           []ResourceRef
+	DisableIPv4         bool
+	DisableIPv6         bool
+	ServerTypeFallbacks []string
+	Labels              map[string]string
+	Project             string
+	CloudConfig         cloudconfig.CloudConfigSpec
+	ControllerID        string
 }
 
 func (r *RunnerSpec) Validate() error {
-	if r.Location == "" {
+	if len(r.Locations) == 0 {
 		return fmt.Errorf("missing region")
 	}
 	if r.BootstrapParams.Name == "" {
@@ -128,12 +310,20 @@ func (r *RunnerSpec) Validate() error {
 }
 
 func (r *RunnerSpec) MergeExtraSpecs(extraSpecs *extraSpecs) {
+	for _, profile := range extraSpecs.profiles {
+		r.MergeExtraSpecs(profile)
+	}
+
 	if extraSpecs.SSHKeys != nil {
 		r.SSHKeys = extraSpecs.SSHKeys
 	}
 
+	if extraSpecs.ExtraPackages != nil {
+		r.ExtraPackages = extraSpecs.ExtraPackages
+	}
+
 	if extraSpecs.Location != nil {
-		r.Location = *extraSpecs.Location
+		r.Locations = extraSpecs.Location
 	}
 
 	if extraSpecs.PlacementGroup != nil {
@@ -163,6 +353,39 @@ func (r *RunnerSpec) MergeExtraSpecs(extraSpecs *extraSpecs) {
 	if extraSpecs.DisableIPv6 != nil {
 		r.DisableIPv6 = *extraSpecs.DisableIPv6
 	}
+
+	if extraSpecs.ServerTypeFallbacks != nil {
+		r.ServerTypeFallbacks = extraSpecs.ServerTypeFallbacks
+	}
+
+	if extraSpecs.Labels != nil {
+		if r.Labels == nil {
+			r.Labels = make(map[string]string, len(extraSpecs.Labels))
+		}
+		maps.Copy(r.Labels, extraSpecs.Labels)
+	}
+
+	if extraSpecs.Project != nil {
+		r.Project = *extraSpecs.Project
+	}
+
+	if extraSpecs.RunnerInstallTemplate != nil {
+		r.CloudConfig.RunnerInstallTemplate = extraSpecs.RunnerInstallTemplate
+	}
+
+	if extraSpecs.PreInstallScripts != nil {
+		if r.CloudConfig.PreInstallScripts == nil {
+			r.CloudConfig.PreInstallScripts = make(map[string][]byte, len(extraSpecs.PreInstallScripts))
+		}
+		maps.Copy(r.CloudConfig.PreInstallScripts, extraSpecs.PreInstallScripts)
+	}
+
+	if extraSpecs.ExtraContext != nil {
+		if r.CloudConfig.ExtraContext == nil {
+			r.CloudConfig.ExtraContext = make(map[string]string, len(extraSpecs.ExtraContext))
+		}
+		maps.Copy(r.CloudConfig.ExtraContext, extraSpecs.ExtraContext)
+	}
 }
 
 func (r *RunnerSpec) ComposeUserData() (string, error) {
@@ -170,6 +393,13 @@ func (r *RunnerSpec) ComposeUserData() (string, error) {
 	bootstrapParams.UserDataOptions.DisableUpdatesOnBoot = r.DisableUpdates
 	bootstrapParams.UserDataOptions.ExtraPackages = r.ExtraPackages
 	bootstrapParams.UserDataOptions.EnableBootDebug = r.EnableBootDebug
+	// The cloud config extra specs are merged with the defaults of the provider
+	// config, so they replace the pool extra specs when rendering the userdata.
+	cloudConfigSpecs, err := json.Marshal(r.CloudConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cloud config specs: %w", err)
+	}
+	bootstrapParams.ExtraSpecs = cloudConfigSpecs
 	switch bootstrapParams.OSType {
 	case params.Linux:
 		udata, err := cloudconfig.GetCloudConfig(bootstrapParams, r.Tools, bootstrapParams.Name)
@@ -24,11 +24,11 @@ func TestExtraSpecsFromBootstrapData(t *testing.T) {
 				ExtraSpecs: json.RawMessage(`{"placement_group": 444444, "firewalls": [222222, 333333], "networks": [111111], "location": "nbg1", "ssh_keys": [123456], "disable_updates": true, "enable_boot_debug": true, "extra_packages": ["package1", "package2"], "runner_install_template": "IyEvYmluL2Jhc2gKZWNobyBJbnN0YWxsaW5nIHJ1bm5lci4uLg==", "pre_install_scripts": {"setup.sh": "IyEvYmluL2Jhc2gKZWNobyBTZXR1cCBzY3JpcHQuLi4="}}`),
 			},
 			expectedOutput: &extraSpecs{
-				Location:        hcloud.Ptr("nbg1"),
-				SSHKeys:         []int64{123456},
-				PlacementGroup:  hcloud.Ptr(int64(444444)),
-				Networks:        []int64{111111},
-				Firewalls:       []int64{222222, 333333},
+				Location:        config.Locations{"nbg1"},
+				SSHKeys:         []ResourceRef{"123456"},
+				PlacementGroup:  hcloud.Ptr(ResourceRef("444444")),
+				Networks:        []ResourceRef{"111111"},
+				Firewalls:       []ResourceRef{"222222", "333333"},
 				DisableUpdates:  hcloud.Ptr(true),
 				EnableBootDebug: hcloud.Ptr(true),
 				ExtraPackages:   []string{"package1", "package2"},
@@ -49,6 +49,125 @@ func TestExtraSpecsFromBootstrapData(t *testing.T) {
 			expectedOutput: &extraSpecs{},
 			errString:      "",
 		},
+		{
+			name: "test single profile",
+			input: params.BootstrapInstance{
+				ExtraSpecs: json.RawMessage(`{"profile": "public-ci"}`),
+			},
+			expectedOutput: &extraSpecs{
+				Profile: ProfileNames{"public-ci"},
+			},
+			errString: "",
+		},
+		{
+			name: "test profile list",
+			input: params.BootstrapInstance{
+				ExtraSpecs: json.RawMessage(`{"profile": ["private-only", "gpu-build"]}`),
+			},
+			expectedOutput: &extraSpecs{
+				Profile: ProfileNames{"private-only", "gpu-build"},
+			},
+			errString: "",
+		},
+		{
+			name: "test empty profile",
+			input: params.BootstrapInstance{
+				ExtraSpecs: json.RawMessage(`{"profile": ""}`),
+			},
+			expectedOutput: nil,
+			errString:      "schema validation failed",
+		},
+		{
+			name: "test location list",
+			input: params.BootstrapInstance{
+				ExtraSpecs: json.RawMessage(`{"location": ["nbg1", "fsn1"]}`),
+			},
+			expectedOutput: &extraSpecs{
+				Location: config.Locations{"nbg1", "fsn1"},
+			},
+			errString: "",
+		},
+		{
+			name: "test invalid location list",
+			input: params.BootstrapInstance{
+				ExtraSpecs: json.RawMessage(`{"location": ["nbg1", 1]}`),
+			},
+			expectedOutput: nil,
+			errString:      "location.1: Invalid type. Expected: string, given: integer",
+		},
+		{
+			name: "test server type fallbacks",
+			input: params.BootstrapInstance{
+				ExtraSpecs: json.RawMessage(`{"server_type_fallbacks": ["cpx31", "cx32"]}`),
+			},
+			expectedOutput: &extraSpecs{
+				ServerTypeFallbacks: []string{"cpx31", "cx32"},
+			},
+			errString: "",
+		},
+		{
+			name: "test invalid server_type_fallbacks",
+			input: params.BootstrapInstance{
+				ExtraSpecs: json.RawMessage(`{"server_type_fallbacks": "cpx31"}`),
+			},
+			expectedOutput: nil,
+			errString:      "server_type_fallbacks: Invalid type. Expected: array, given: string",
+		},
+		{
+			name: "test resources by name and label selector",
+			input: params.BootstrapInstance{
+				ExtraSpecs: json.RawMessage(`{"ssh_keys": ["deploy", 123456], "placement_group": "spread", "networks": ["label:env=prod"], "firewalls": ["runners"]}`),
+			},
+			expectedOutput: &extraSpecs{
+				SSHKeys:        []ResourceRef{"deploy", "123456"},
+				PlacementGroup: hcloud.Ptr(ResourceRef("spread")),
+				Networks:       []ResourceRef{"label:env=prod"},
+				Firewalls:      []ResourceRef{"runners"},
+			},
+			errString: "",
+		},
+		{
+			name: "test empty resource name",
+			input: params.BootstrapInstance{
+				ExtraSpecs: json.RawMessage(`{"ssh_keys": [""]}`),
+			},
+			expectedOutput: nil,
+			errString:      "ssh_keys.0: String length must be greater than or equal to 1",
+		},
+		{
+			name: "test labels",
+			input: params.BootstrapInstance{
+				ExtraSpecs: json.RawMessage(`{"labels": {"team": "platform", "cost-centre": "cc-1234"}}`),
+			},
+			expectedOutput: &extraSpecs{
+				Labels: map[string]string{"team": "platform", "cost-centre": "cc-1234"},
+			},
+			errString: "",
+		},
+		{
+			name: "test invalid labels",
+			input: params.BootstrapInstance{
+				ExtraSpecs: json.RawMessage(`{"labels": {"team": 1}}`),
+			},
+			expectedOutput: nil,
+			errString:      "labels.team: Invalid type. Expected: string, given: integer",
+		},
+		{
+			name: "test invalid label value",
+			input: params.BootstrapInstance{
+				ExtraSpecs: json.RawMessage(`{"labels": {"team": "platform team"}}`),
+			},
+			expectedOutput: nil,
+			errString:      "invalid labels: invalid value for label \"team\"",
+		},
+		{
+			name: "test reserved label",
+			input: params.BootstrapInstance{
+				ExtraSpecs: json.RawMessage(`{"labels": {"GARM_CONTROLLER_ID": "controller"}}`),
+			},
+			expectedOutput: nil,
+			errString:      "invalid labels: label \"GARM_CONTROLLER_ID\" is reserved",
+		},
 		{
 			name: "test invalid location",
 			input: params.BootstrapInstance{
@@ -187,16 +306,16 @@ func TestGetRunnerSpecFromBootstrapParams(t *testing.T) {
 	}
 
 	config := &config.Config{
-		Location: "nbg1",
+		Location: config.Locations{"nbg1"},
 		Token:    "hcloud-token",
 	}
 	expectedRunnerSpec := &RunnerSpec{
-		Location:        "nbg1",
+		Locations:       []string{"nbg1"},
 		ExtraPackages:   []string{"package1", "package2"},
-		SSHKeys:         []int64{123456},
-		PlacementGroup:  444444,
-		Networks:        []int64{111111},
-		Firewalls:       []int64{222222, 333333},
+		SSHKeys:         []ResourceRef{"123456"},
+		PlacementGroup:  "444444",
+		Networks:        []ResourceRef{"111111"},
+		Firewalls:       []ResourceRef{"222222", "333333"},
 		DisableUpdates:  true,
 		EnableBootDebug: true,
 		Tools:           Mocktools,
@@ -204,6 +323,12 @@ func TestGetRunnerSpecFromBootstrapParams(t *testing.T) {
 		BootstrapParams: data,
 		DisableIPv4:     false,
 		DisableIPv6:     true,
+		CloudConfig: cloudconfig.CloudConfigSpec{
+			RunnerInstallTemplate: []byte("#!/bin/bash\necho Installing runner..."),
+			PreInstallScripts: map[string][]byte{
+				"setup.sh": []byte("#!/bin/bash\necho Setup script..."),
+			},
+		},
 	}
 
 	runnerSpec, err := GetRunnerSpecFromBootstrapParams(config, data, "controller_id")
@@ -211,6 +336,351 @@ func TestGetRunnerSpecFromBootstrapParams(t *testing.T) {
 	require.Equal(t, expectedRunnerSpec, runnerSpec)
 }
 
+func TestGetRunnerSpecFromBootstrapParamsDefaults(t *testing.T) {
+	DefaultToolFetch = func(osType params.OSType, osArch params.OSArch, tools []params.RunnerApplicationDownload) (params.RunnerApplicationDownload, error) {
+		return params.RunnerApplicationDownload{}, nil
+	}
+
+	data := params.BootstrapInstance{
+		Name:       "mock-name",
+		Image:      "ubuntu-24.04",
+		ExtraSpecs: json.RawMessage(`{"firewalls": [333333], "labels": {"env": "prod"}, "extra_context": {"proxy": "pool"}}`),
+	}
+	cfg := &config.Config{
+		Location: config.Locations{"nbg1"},
+		Token:    "hcloud-token",
+		Defaults: map[string]any{
+			"ssh_keys":        []any{int64(123456), "deploy"},
+			"firewalls":       []any{int64(222222)},
+			"networks":        []any{"label:env=prod"},
+			"disable_updates": true,
+			"labels":          map[string]any{"team": "ci"},
+			"extra_context":   map[string]any{"proxy": "default", "mirror": "default"},
+		},
+	}
+
+	runnerSpec, err := GetRunnerSpecFromBootstrapParams(cfg, data, "controller_id")
+	require.NoError(t, err)
+	require.Equal(t, []ResourceRef{"123456", "deploy"}, runnerSpec.SSHKeys)
+	require.Equal(t, []ResourceRef{"333333"}, runnerSpec.Firewalls)
+	require.Equal(t, []ResourceRef{"label:env=prod"}, runnerSpec.Networks)
+	require.True(t, runnerSpec.DisableUpdates)
+	require.Equal(t, map[string]string{"team": "ci", "env": "prod"}, runnerSpec.Labels)
+	require.Equal(t, map[string]string{"proxy": "pool", "mirror": "default"}, runnerSpec.CloudConfig.ExtraContext)
+}
+
+func TestGetRunnerSpecFromBootstrapParamsProfiles(t *testing.T) {
+	DefaultToolFetch = func(osType params.OSType, osArch params.OSArch, tools []params.RunnerApplicationDownload) (params.RunnerApplicationDownload, error) {
+		return params.RunnerApplicationDownload{}, nil
+	}
+
+	cfg := &config.Config{
+		Location: config.Locations{"nbg1"},
+		Token:    "hcloud-token",
+		Defaults: map[string]any{
+			"ssh_keys":     []any{"deploy"},
+			"disable_ipv4": false,
+		},
+		Profiles: map[string]map[string]any{
+			"private-only": {
+				"disable_ipv4": true,
+				"disable_ipv6": true,
+				"networks":     []any{int64(111111)},
+				"labels":       map[string]any{"network": "private"},
+			},
+			"gpu-build": {
+				"location": []any{"fsn1", "nbg1"},
+				"networks": []any{int64(222222)},
+				"labels":   map[string]any{"shape": "gpu"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		extraSpecs string
+		check      func(t *testing.T, spec *RunnerSpec)
+		errString  string
+	}{
+		{
+			name:       "single profile",
+			extraSpecs: `{"profile": "private-only"}`,
+			check: func(t *testing.T, spec *RunnerSpec) {
+				require.Equal(t, []ResourceRef{"deploy"}, spec.SSHKeys)
+				require.True(t, spec.DisableIPv4)
+				require.True(t, spec.DisableIPv6)
+				require.Equal(t, []ResourceRef{"111111"}, spec.Networks)
+				require.Equal(t, []string{"nbg1"}, spec.Locations)
+			},
+		},
+		{
+			name:       "profiles merged in order",
+			extraSpecs: `{"profile": ["private-only", "gpu-build"]}`,
+			check: func(t *testing.T, spec *RunnerSpec) {
+				require.True(t, spec.DisableIPv4)
+				require.Equal(t, []ResourceRef{"222222"}, spec.Networks)
+				require.Equal(t, []string{"fsn1", "nbg1"}, spec.Locations)
+				require.Equal(t, map[string]string{"network": "private", "shape": "gpu"}, spec.Labels)
+			},
+		},
+		{
+			name:       "pool overrides profile",
+			extraSpecs: `{"profile": "private-only", "disable_ipv4": false, "labels": {"network": "public"}}`,
+			check: func(t *testing.T, spec *RunnerSpec) {
+				require.False(t, spec.DisableIPv4)
+				require.True(t, spec.DisableIPv6)
+				require.Equal(t, map[string]string{"network": "public"}, spec.Labels)
+			},
+		},
+		{
+			name:       "unknown profile",
+			extraSpecs: `{"profile": ["private-only", "public-ci"]}`,
+			errString:  "error loading extra specs: unknown profile \"public-ci\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := params.BootstrapInstance{
+				Name:       "mock-name",
+				Image:      "ubuntu-24.04",
+				ExtraSpecs: json.RawMessage(tt.extraSpecs),
+			}
+			spec, err := GetRunnerSpecFromBootstrapParams(cfg, data, "controller_id")
+			if tt.errString != "" {
+				require.EqualError(t, err, tt.errString)
+				return
+			}
+			require.NoError(t, err)
+			tt.check(t, spec)
+		})
+	}
+}
+
+func TestGetRunnerSpecFromBootstrapParamsProject(t *testing.T) {
+	DefaultToolFetch = func(osType params.OSType, osArch params.OSArch, tools []params.RunnerApplicationDownload) (params.RunnerApplicationDownload, error) {
+		return params.RunnerApplicationDownload{}, nil
+	}
+
+	cfg := &config.Config{
+		Location: config.Locations{"nbg1"},
+		Token:    "hcloud-token",
+		Projects: map[string]config.Project{
+			"overflow": {Token: "overflow-token", Location: config.Locations{"fsn1"}},
+			"other":    {Token: "other-token"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		extraSpecs string
+		project    string
+		locations  []string
+		errString  string
+	}{
+		{
+			name:       "default project",
+			extraSpecs: `{}`,
+			locations:  []string{"nbg1"},
+		},
+		{
+			name:       "project location",
+			extraSpecs: `{"project": "overflow"}`,
+			project:    "overflow",
+			locations:  []string{"fsn1"},
+		},
+		{
+			name:       "project without location",
+			extraSpecs: `{"project": "other"}`,
+			project:    "other",
+			locations:  []string{"nbg1"},
+		},
+		{
+			name:       "pool location overrides project location",
+			extraSpecs: `{"project": "overflow", "location": "hel1"}`,
+			project:    "overflow",
+			locations:  []string{"hel1"},
+		},
+		{
+			name:       "unknown project",
+			extraSpecs: `{"project": "missing"}`,
+			errString:  "error loading extra specs: unknown project \"missing\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := params.BootstrapInstance{
+				Name:       "mock-name",
+				Image:      "ubuntu-24.04",
+				ExtraSpecs: json.RawMessage(tt.extraSpecs),
+			}
+			spec, err := GetRunnerSpecFromBootstrapParams(cfg, data, "controller_id")
+			if tt.errString != "" {
+				require.EqualError(t, err, tt.errString)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.project, spec.Project)
+			require.Equal(t, tt.locations, spec.Locations)
+		})
+	}
+}
+
+func TestGetRunnerSpecFromBootstrapParamsProjectDefaults(t *testing.T) {
+	DefaultToolFetch = func(osType params.OSType, osArch params.OSArch, tools []params.RunnerApplicationDownload) (params.RunnerApplicationDownload, error) {
+		return params.RunnerApplicationDownload{}, nil
+	}
+
+	cfg := &config.Config{
+		Location: config.Locations{"fsn1"},
+		Token:    "hcloud-token",
+		Defaults: map[string]any{"location": "nbg1"},
+		Profiles: map[string]map[string]any{
+			"overflow": {"project": "over"},
+			"virginia": {"location": "ash"},
+		},
+		Projects: map[string]config.Project{
+			"over":  {Token: "over-token", Location: config.Locations{"hel1"}},
+			"other": {Token: "other-token"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		extraSpecs string
+		locations  []string
+	}{
+		{
+			name:       "defaults location",
+			extraSpecs: `{}`,
+			locations:  []string{"nbg1"},
+		},
+		{
+			name:       "project location overrides defaults",
+			extraSpecs: `{"project": "over"}`,
+			locations:  []string{"hel1"},
+		},
+		{
+			name:       "project from profile overrides defaults",
+			extraSpecs: `{"profile": "overflow"}`,
+			locations:  []string{"hel1"},
+		},
+		{
+			name:       "project without location keeps defaults",
+			extraSpecs: `{"project": "other"}`,
+			locations:  []string{"nbg1"},
+		},
+		{
+			name:       "profile location overrides project",
+			extraSpecs: `{"project": "over", "profile": "virginia"}`,
+			locations:  []string{"ash"},
+		},
+		{
+			name:       "pool location overrides project",
+			extraSpecs: `{"project": "over", "location": "fsn1"}`,
+			locations:  []string{"fsn1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := params.BootstrapInstance{
+				Name:       "mock-name",
+				Image:      "ubuntu-24.04",
+				ExtraSpecs: json.RawMessage(tt.extraSpecs),
+			}
+			spec, err := GetRunnerSpecFromBootstrapParams(cfg, data, "controller_id")
+			require.NoError(t, err)
+			require.Equal(t, tt.locations, spec.Locations)
+		})
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		profiles  map[string]map[string]any
+		errString string
+	}{
+		{
+			name: "no profiles",
+		},
+		{
+			name:     "valid profiles",
+			profiles: map[string]map[string]any{"public-ci": {"firewalls": []any{"label:role=runner"}}},
+		},
+		{
+			name:      "unknown field",
+			profiles:  map[string]map[string]any{"public-ci": {"unknown": "value"}},
+			errString: "invalid profile \"public-ci\": failed to validate extra specs: schema validation failed",
+		},
+		{
+			name:      "nested profile",
+			profiles:  map[string]map[string]any{"public-ci": {"profile": "private-only"}, "private-only": {}},
+			errString: "invalid profile \"public-ci\": profiles can't reference other profiles",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateProfiles(&config.Config{Profiles: tt.profiles})
+			if tt.errString == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tt.errString)
+			}
+		})
+	}
+}
+
+func TestValidateDefaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		defaults  map[string]any
+		errString string
+	}{
+		{
+			name: "no defaults",
+		},
+		{
+			name:     "valid defaults",
+			defaults: map[string]any{"ssh_keys": []any{int64(123456)}, "location": "nbg1"},
+		},
+		{
+			name:      "unknown field",
+			defaults:  map[string]any{"unknown": "value"},
+			errString: "invalid defaults: failed to validate extra specs: schema validation failed",
+		},
+		{
+			name:      "wrong type",
+			defaults:  map[string]any{"disable_ipv4": "yes"},
+			errString: "invalid defaults: failed to validate extra specs: schema validation failed",
+		},
+		{
+			name:      "unknown profile",
+			defaults:  map[string]any{"profile": "public-ci"},
+			errString: "invalid defaults: unknown profile \"public-ci\"",
+		},
+		{
+			name:      "reserved label",
+			defaults:  map[string]any{"labels": map[string]any{"Name": "runner"}},
+			errString: "invalid defaults: invalid labels: label \"Name\" is reserved",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDefaults(&config.Config{Defaults: tt.defaults})
+			if tt.errString == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tt.errString)
+			}
+		})
+	}
+}
+
 func TestRunnerSpecValidate(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -225,7 +695,7 @@ func TestRunnerSpecValidate(t *testing.T) {
 		{
 			name: "missing name",
 			spec: &RunnerSpec{
-				Location: "location",
+				Locations: []string{"location"},
 				BootstrapParams: params.BootstrapInstance{
 					Image: "ubuntu-24.04",
 				},
@@ -235,7 +705,7 @@ func TestRunnerSpecValidate(t *testing.T) {
 		{
 			name: "missing image",
 			spec: &RunnerSpec{
-				Location: "location",
+				Locations: []string{"location"},
 				BootstrapParams: params.BootstrapInstance{
 					Name: "name",
 				},
@@ -245,12 +715,12 @@ func TestRunnerSpecValidate(t *testing.T) {
 		{
 			name: "valid runner spec",
 			spec: &RunnerSpec{
-				Location:        "nbg1",
+				Locations:       []string{"nbg1"},
 				ExtraPackages:   []string{"package1", "package2"},
-				SSHKeys:         []int64{123456},
-				PlacementGroup:  444444,
-				Networks:        []int64{111111},
-				Firewalls:       []int64{222222, 333333},
+				SSHKeys:         []ResourceRef{"123456"},
+				PlacementGroup:  "444444",
+				Networks:        []ResourceRef{"111111"},
+				Firewalls:       []ResourceRef{"222222", "333333"},
 				DisableUpdates:  true,
 				EnableBootDebug: true,
 				Tools: params.RunnerApplicationDownload{
@@ -291,33 +761,49 @@ func TestMergeExtraSpecs(t *testing.T) {
 		{
 			name: "empty extra specs",
 			spec: &RunnerSpec{
-				Location: "location",
+				Locations: []string{"location"},
 			},
 			extra:    &extraSpecs{},
-			expected: &RunnerSpec{Location: "location"},
+			expected: &RunnerSpec{Locations: []string{"location"}},
+		},
+		{
+			name: "labels merged with config labels",
+			spec: &RunnerSpec{
+				Locations: []string{"location"},
+				Labels:    map[string]string{"team": "platform", "cost-centre": "cc-1234"},
+			},
+			extra: &extraSpecs{
+				Labels: map[string]string{"team": "ci", "env": "prod"},
+			},
+			expected: &RunnerSpec{
+				Locations: []string{"location"},
+				Labels:    map[string]string{"team": "ci", "cost-centre": "cc-1234", "env": "prod"},
+			},
 		},
 		{
 			name: "valid extra specs",
 			spec: &RunnerSpec{
-				Location: "location",
+				Locations: []string{"location"},
 			},
 			extra: &extraSpecs{
-				Location:        hcloud.Ptr("nbg1"),
-				SSHKeys:         []int64{123456},
-				PlacementGroup:  hcloud.Ptr(int64(444444)),
-				Networks:        []int64{111111},
-				Firewalls:       []int64{222222, 333333},
-				DisableUpdates:  hcloud.Ptr(true),
-				EnableBootDebug: hcloud.Ptr(true),
+				Location:            config.Locations{"nbg1"},
+				SSHKeys:             []ResourceRef{"123456"},
+				PlacementGroup:      hcloud.Ptr(ResourceRef("444444")),
+				Networks:            []ResourceRef{"111111"},
+				Firewalls:           []ResourceRef{"222222", "333333"},
+				DisableUpdates:      hcloud.Ptr(true),
+				EnableBootDebug:     hcloud.Ptr(true),
+				ServerTypeFallbacks: []string{"cpx31"},
 			},
 			expected: &RunnerSpec{
-				Location:        "nbg1",
-				SSHKeys:         []int64{123456},
-				PlacementGroup:  444444,
-				Networks:        []int64{111111},
-				Firewalls:       []int64{222222, 333333},
-				DisableUpdates:  true,
-				EnableBootDebug: true,
+				Locations:           []string{"nbg1"},
+				SSHKeys:             []ResourceRef{"123456"},
+				PlacementGroup:      "444444",
+				Networks:            []ResourceRef{"111111"},
+				Firewalls:           []ResourceRef{"222222", "333333"},
+				DisableUpdates:      true,
+				EnableBootDebug:     true,
+				ServerTypeFallbacks: []string{"cpx31"},
 			},
 		},
 	}
@@ -329,3 +815,19 @@ func TestMergeExtraSpecs(t *testing.T) {
 		})
 	}
 }
+
+func TestResourceRef(t *testing.T) {
+	id, ok := ResourceRef("123456").ID()
+	require.True(t, ok)
+	require.Equal(t, int64(123456), id)
+
+	_, ok = ResourceRef("deploy").ID()
+	require.False(t, ok)
+
+	selector, ok := ResourceRef("label:env=prod,team=ci").LabelSelector()
+	require.True(t, ok)
+	require.Equal(t, "env=prod,team=ci", selector)
+
+	_, ok = ResourceRef("deploy").LabelSelector()
+	require.False(t, ok)
+}
@@ -17,6 +17,14 @@ var signals = []os.Signal{
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == encryptTokenCommand {
+		if err := encryptToken(os.Args[2:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encrypting token: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), signals...)
 	defer stop()
 
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/cloudbase/garm-provider-common/params"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/imtf-group/garm-provider-hetzner/internal/client"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// projectSeparator separates the project from the server ID in the provider
+// ID of the servers of named projects, e.g. "proj:12345". Servers of the
+// default project keep a bare ID.
+const projectSeparator = ":"
+
+func splitProviderID(providerID string) (string, string) {
+	if project, instance, ok := strings.Cut(providerID, projectSeparator); ok {
+		return project, instance
+	}
+	return "", providerID
+}
+
+// resolveInstance returns the project and server of instance. Servers
+// referenced by name rather than by provider ID are searched in every
+// project, falling back to the default project when none matches.
+func (a *HcloudProvider) resolveInstance(ctx context.Context, instance string) (string, string, error) {
+	project, instance := splitProviderID(instance)
+	if project != "" {
+		return project, instance, nil
+	}
+	if _, err := strconv.ParseInt(instance, 10, 64); err == nil {
+		return project, instance, nil
+	}
+	for _, name := range a.projectNames() {
+		projectClient, err := a.clientFor(name)
+		if err != nil {
+			return "", "", err
+		}
+		server, err := projectClient.GetInstance(ctx, instance, true)
+		if err != nil {
+			return "", "", err
+		}
+		if server != nil {
+			return name, instance, nil
+		}
+	}
+	return "", instance, nil
+}
+
+func deserializeInstance(project string, server *hcloud.Server) params.ProviderInstance {
+	instance := client.DeserializeInstance(server)
+	if project != "" {
+		instance.ProviderID = project + projectSeparator + instance.ProviderID
+	}
+	return instance
+}
+
+// clientFor returns the client of the named project, or the client of the
+// default project when project is empty.
+func (a *HcloudProvider) clientFor(project string) (*client.HcloudClient, error) {
+	if project == "" {
+		return a.client, nil
+	}
+	projectClient, ok := a.projects[project]
+	if !ok {
+		return nil, fmt.Errorf("unknown project %q", project)
+	}
+	return projectClient, nil
+}
+
+// projectNames returns the default project followed by the named projects.
+func (a *HcloudProvider) projectNames() []string {
+	return append([]string{""}, slices.Sorted(maps.Keys(a.projects))...)
+}
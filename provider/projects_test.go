@@ -0,0 +1,239 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/cloudbase/garm-provider-common/params"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/imtf-group/garm-provider-hetzner/config"
+	"github.com/imtf-group/garm-provider-hetzner/internal/client"
+	"github.com/imtf-group/garm-provider-hetzner/internal/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+func newProjectsProvider() (*HcloudProvider, *client.MockHCloudAPI, *client.MockHCloudAPI) {
+	cfg := &config.Config{
+		Location: config.Locations{"nbg1"},
+		Token:    "mysecret",
+		Projects: map[string]config.Project{
+			"proj": {
+				Location: config.Locations{"fsn1"},
+				Token:    "projsecret",
+			},
+		},
+	}
+	projectCfg, _ := cfg.ProjectConfig("proj")
+
+	defaultAPI := new(client.MockHCloudAPI)
+	projectAPI := new(client.MockHCloudAPI)
+	provider := &HcloudProvider{
+		controllerID: "controllerID",
+		client:       &client.HcloudClient{},
+		projects: map[string]*client.HcloudClient{
+			"proj": &client.HcloudClient{},
+		},
+	}
+	provider.client.SetConfig(cfg)
+	provider.client.SetApi(defaultAPI)
+	provider.projects["proj"].SetConfig(projectCfg)
+	provider.projects["proj"].SetApi(projectAPI)
+	return provider, defaultAPI, projectAPI
+}
+
+func TestSplitProviderID(t *testing.T) {
+	project, instance := splitProviderID("proj:123456")
+	assert.Equal(t, "proj", project)
+	assert.Equal(t, "123456", instance)
+
+	project, instance = splitProviderID("123456")
+	assert.Equal(t, "", project)
+	assert.Equal(t, "123456", instance)
+}
+
+func TestCreateInstanceProject(t *testing.T) {
+	ctx := context.Background()
+	spec.DefaultToolFetch = func(osType params.OSType, osArch params.OSArch, tools []params.RunnerApplicationDownload) (params.RunnerApplicationDownload, error) {
+		return params.RunnerApplicationDownload{
+			OS:           hcloud.Ptr("linux"),
+			Architecture: hcloud.Ptr("amd64"),
+			DownloadURL:  hcloud.Ptr("MockURL"),
+			Filename:     hcloud.Ptr("garm-runner"),
+		}, nil
+	}
+	provider, defaultAPI, projectAPI := newProjectsProvider()
+	bootstrapParams := params.BootstrapInstance{
+		Name:       "garm-instance",
+		Flavor:     "cx22",
+		Image:      "ubuntu-22.04",
+		OSType:     params.Linux,
+		OSArch:     params.Amd64,
+		PoolID:     "my-pool",
+		ExtraSpecs: json.RawMessage(`{"project": "proj"}`),
+	}
+
+	projectAPI.On("GetServersByLabel", ctx, "GARM_CONTROLLER_ID=controllerID,GARM_POOL_ID=my-pool").Return([]*hcloud.Server{}, nil)
+	projectAPI.On("GetServerType", ctx, "cx22").Return(&hcloud.ServerType{
+		Name:         "cx22",
+		Architecture: hcloud.ArchitectureX86,
+	}, &hcloud.Response{}, nil)
+	projectAPI.On("GetImage", ctx, "ubuntu-22.04", hcloud.ArchitectureX86).Return(&hcloud.Image{
+		ID:           67794396,
+		Name:         "ubuntu-22.04",
+		Architecture: hcloud.ArchitectureX86,
+	}, &hcloud.Response{}, nil)
+	projectAPI.On("CreateServer", ctx, mock.MatchedBy(func(opts hcloud.ServerCreateOpts) bool {
+		return opts.Location != nil && opts.Location.Name == "fsn1"
+	})).Return(hcloud.ServerCreateResult{
+		Server: &hcloud.Server{
+			ID:     123456,
+			Status: hcloud.ServerStatusInitializing,
+		},
+	}, &hcloud.Response{}, nil)
+
+	instance, err := provider.CreateInstance(ctx, bootstrapParams)
+	assert.NoError(t, err)
+	assert.Equal(t, "proj:123456", instance.ProviderID)
+	projectAPI.AssertExpectations(t)
+	defaultAPI.AssertNotCalled(t, "CreateServer", mock.Anything, mock.Anything)
+}
+
+func TestCreateInstanceUnknownProject(t *testing.T) {
+	ctx := context.Background()
+	spec.DefaultToolFetch = func(osType params.OSType, osArch params.OSArch, tools []params.RunnerApplicationDownload) (params.RunnerApplicationDownload, error) {
+		return params.RunnerApplicationDownload{}, nil
+	}
+	provider, _, _ := newProjectsProvider()
+	bootstrapParams := params.BootstrapInstance{
+		Name:       "garm-instance",
+		Flavor:     "cx22",
+		Image:      "ubuntu-22.04",
+		OSType:     params.Linux,
+		OSArch:     params.Amd64,
+		ExtraSpecs: json.RawMessage(`{"project": "other"}`),
+	}
+
+	_, err := provider.CreateInstance(ctx, bootstrapParams)
+	assert.ErrorContains(t, err, `unknown project "other"`)
+}
+
+func TestGetInstanceProject(t *testing.T) {
+	ctx := context.Background()
+	provider, defaultAPI, projectAPI := newProjectsProvider()
+	projectAPI.On("GetServer", ctx, "123456").Return(&hcloud.Server{
+		ID:     123456,
+		Status: hcloud.ServerStatusRunning,
+	}, &hcloud.Response{}, nil)
+	defaultAPI.On("GetServer", ctx, "234567").Return(&hcloud.Server{
+		ID:     234567,
+		Status: hcloud.ServerStatusRunning,
+	}, &hcloud.Response{}, nil)
+
+	instance, err := provider.GetInstance(ctx, "proj:123456")
+	assert.NoError(t, err)
+	assert.Equal(t, "proj:123456", instance.ProviderID)
+
+	instance, err = provider.GetInstance(ctx, "234567")
+	assert.NoError(t, err)
+	assert.Equal(t, "234567", instance.ProviderID)
+
+	_, err = provider.GetInstance(ctx, "other:123456")
+	assert.EqualError(t, err, `failed to get VM details: unknown project "other"`)
+
+	projectAPI.AssertExpectations(t)
+	defaultAPI.AssertExpectations(t)
+}
+
+func TestDeleteInstanceProject(t *testing.T) {
+	ctx := context.Background()
+	provider, _, projectAPI := newProjectsProvider()
+	server := &hcloud.Server{ID: 123456}
+	projectAPI.On("GetServer", ctx, "123456").Return(server, &hcloud.Response{}, nil)
+	projectAPI.On("DeleteServer", ctx, server).Return(&hcloud.Response{}, nil)
+
+	err := provider.DeleteInstance(ctx, "proj:123456")
+	assert.NoError(t, err)
+	projectAPI.AssertExpectations(t)
+}
+
+func TestInstanceByNameProjects(t *testing.T) {
+	ctx := context.Background()
+	provider, defaultAPI, projectAPI := newProjectsProvider()
+	server := &hcloud.Server{ID: 123456, Name: "garm-instance", Status: hcloud.ServerStatusRunning}
+	defaultAPI.On("GetServer", ctx, "garm-instance").Return(nil, &hcloud.Response{}, nil)
+	projectAPI.On("GetServer", ctx, "garm-instance").Return(server, &hcloud.Response{}, nil)
+	projectAPI.On("DeleteServer", ctx, server).Return(&hcloud.Response{}, nil)
+
+	instance, err := provider.GetInstance(ctx, "garm-instance")
+	assert.NoError(t, err)
+	assert.Equal(t, "proj:123456", instance.ProviderID)
+
+	err = provider.DeleteInstance(ctx, "garm-instance")
+	assert.NoError(t, err)
+	defaultAPI.AssertExpectations(t)
+	projectAPI.AssertExpectations(t)
+}
+
+func TestInstanceByNameNotFound(t *testing.T) {
+	ctx := context.Background()
+	provider, defaultAPI, projectAPI := newProjectsProvider()
+	defaultAPI.On("GetServer", ctx, "garm-instance").Return(nil, &hcloud.Response{}, nil)
+	projectAPI.On("GetServer", ctx, "garm-instance").Return(nil, &hcloud.Response{}, nil)
+
+	_, err := provider.GetInstance(ctx, "garm-instance")
+	assert.ErrorIs(t, err, client.ErrNotFound)
+
+	err = provider.DeleteInstance(ctx, "garm-instance")
+	assert.NoError(t, err)
+	defaultAPI.AssertExpectations(t)
+	projectAPI.AssertExpectations(t)
+}
+
+func TestStartStopProject(t *testing.T) {
+	ctx := context.Background()
+	provider, _, projectAPI := newProjectsProvider()
+	off := &hcloud.Server{ID: 123456, Status: hcloud.ServerStatusOff}
+	running := &hcloud.Server{ID: 234567, Status: hcloud.ServerStatusRunning}
+	projectAPI.On("GetServer", ctx, "123456").Return(off, &hcloud.Response{}, nil)
+	projectAPI.On("StartServer", ctx, off).Return(&hcloud.Action{}, &hcloud.Response{}, nil)
+	projectAPI.On("GetServer", ctx, "234567").Return(running, &hcloud.Response{}, nil)
+	projectAPI.On("StopServer", ctx, running).Return(&hcloud.Action{}, &hcloud.Response{}, nil)
+
+	assert.NoError(t, provider.Start(ctx, "proj:123456"))
+	assert.NoError(t, provider.Stop(ctx, "proj:234567", true))
+	assert.EqualError(t, provider.Start(ctx, "other:123456"), `unknown project "other"`)
+	projectAPI.AssertExpectations(t)
+}
+
+func TestListInstancesProjects(t *testing.T) {
+	ctx := context.Background()
+	provider, defaultAPI, projectAPI := newProjectsProvider()
+	selector := "GARM_CONTROLLER_ID=controllerID,GARM_POOL_ID=09876-54321"
+	defaultAPI.On("GetServersByLabel", ctx, selector).Return([]*hcloud.Server{
+		{ID: 123456, Status: hcloud.ServerStatusRunning},
+	}, nil)
+	projectAPI.On("GetServersByLabel", ctx, selector).Return([]*hcloud.Server{
+		{ID: 234567, Status: hcloud.ServerStatusRunning},
+	}, nil)
+
+	instances, err := provider.ListInstances(ctx, "09876-54321")
+	assert.NoError(t, err)
+	assert.Len(t, instances, 2)
+	assert.Equal(t, "123456", instances[0].ProviderID)
+	assert.Equal(t, "proj:234567", instances[1].ProviderID)
+	defaultAPI.AssertExpectations(t)
+	projectAPI.AssertExpectations(t)
+}
+
+func TestRemoveAllInstancesProjects(t *testing.T) {
+	ctx := context.Background()
+	provider, defaultAPI, projectAPI := newProjectsProvider()
+	defaultAPI.On("GetServersByLabel", ctx, "GARM_CONTROLLER_ID=controllerID").Return([]*hcloud.Server{}, nil)
+	projectAPI.On("GetServersByLabel", ctx, "GARM_CONTROLLER_ID=controllerID").Return([]*hcloud.Server{}, nil)
+
+	err := provider.RemoveAllInstances(ctx)
+	assert.NoError(t, err)
+	defaultAPI.AssertExpectations(t)
+	projectAPI.AssertExpectations(t)
+}
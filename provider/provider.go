@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	execution "github.com/cloudbase/garm-provider-common/execution/v0.1.0"
 	"github.com/cloudbase/garm-provider-common/params"
@@ -17,19 +18,41 @@ func NewHcloudProvider(ctx context.Context, configPath, controllerID string) (ex
 	if err != nil {
 		return nil, fmt.Errorf("error loading config: %w", err)
 	}
-	client, err := client.NewClient(ctx, conf)
+	if err := spec.ValidateProfiles(conf); err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+	if err := spec.ValidateDefaults(conf); err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+	hcloudClient, err := client.NewClient(ctx, conf)
 	if err != nil {
 		return nil, fmt.Errorf("error getting the client: %w", err)
 	}
+	projects := make(map[string]*client.HcloudClient, len(conf.Projects))
+	for name := range conf.Projects {
+		projectConf, err := conf.ProjectConfig(name)
+		if err != nil {
+			return nil, fmt.Errorf("error loading config: %w", err)
+		}
+		projectClient, err := client.NewClient(ctx, projectConf)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the client of project %q: %w", name, err)
+		}
+		projects[name] = projectClient
+	}
 	return &HcloudProvider{
 		controllerID: controllerID,
-		client:       client,
+		client:       hcloudClient,
+		projects:     projects,
 	}, nil
 }
 
 type HcloudProvider struct {
 	controllerID string
-	client       *client.HcloudClient
+	// client manages the servers of the default project, and projects the
+	// servers of the named projects of the config.
+	client   *client.HcloudClient
+	projects map[string]*client.HcloudClient
 }
 
 func (a *HcloudProvider) CreateInstance(ctx context.Context, bootstrapParams params.BootstrapInstance) (params.ProviderInstance, error) {
@@ -38,24 +61,39 @@ func (a *HcloudProvider) CreateInstance(ctx context.Context, bootstrapParams par
 		return params.ProviderInstance{}, fmt.Errorf("failed to get runner spec: %w", err)
 	}
 
-	instanceID, err := a.client.CreateInstance(ctx, spec)
+	projectClient, err := a.clientFor(spec.Project)
+	if err != nil {
+		return params.ProviderInstance{}, fmt.Errorf("failed to create instance: %w", err)
+	}
+
+	server, err := projectClient.CreateInstance(ctx, spec)
 	if err != nil {
 		return params.ProviderInstance{}, fmt.Errorf("failed to create instance: %w", err)
 	}
 
-	instance := params.ProviderInstance{
-		ProviderID: instanceID,
-		Name:       spec.BootstrapParams.Name,
-		OSType:     spec.BootstrapParams.OSType,
-		OSArch:     spec.BootstrapParams.OSArch,
-		Status:     "running",
+	instance := deserializeInstance(spec.Project, server)
+	instance.Name = spec.BootstrapParams.Name
+	instance.OSType = spec.BootstrapParams.OSType
+	instance.OSArch = spec.BootstrapParams.OSArch
+	// The creation succeeded, GARM tracks the bootstrap through the runner
+	// status from now on.
+	if instance.Status == params.InstanceCreating {
+		instance.Status = params.InstanceRunning
 	}
 
 	return instance, nil
 }
 
 func (a *HcloudProvider) DeleteInstance(ctx context.Context, instance string) error {
-	if err := a.client.DeleteInstance(ctx, instance); err != nil {
+	project, instance, err := a.resolveInstance(ctx, instance)
+	if err != nil {
+		return fmt.Errorf("failed to terminate instance: %w", err)
+	}
+	projectClient, err := a.clientFor(project)
+	if err != nil {
+		return fmt.Errorf("failed to terminate instance: %w", err)
+	}
+	if err := projectClient.DeleteInstance(ctx, instance); err != nil {
 		return fmt.Errorf("failed to terminate instance: %w", err)
 	}
 
@@ -63,7 +101,15 @@ func (a *HcloudProvider) DeleteInstance(ctx context.Context, instance string) er
 }
 
 func (a *HcloudProvider) GetInstance(ctx context.Context, instance string) (params.ProviderInstance, error) {
-	server, err := a.client.GetInstance(ctx, instance, false)
+	project, instance, err := a.resolveInstance(ctx, instance)
+	if err != nil {
+		return params.ProviderInstance{}, fmt.Errorf("failed to get VM details: %w", err)
+	}
+	projectClient, err := a.clientFor(project)
+	if err != nil {
+		return params.ProviderInstance{}, fmt.Errorf("failed to get VM details: %w", err)
+	}
+	server, err := projectClient.GetInstance(ctx, instance, false)
 	if err != nil {
 		return params.ProviderInstance{}, fmt.Errorf("failed to get VM details: %w", err)
 	}
@@ -71,38 +117,65 @@ func (a *HcloudProvider) GetInstance(ctx context.Context, instance string) (para
 		return params.ProviderInstance{}, nil
 	}
 
-	providerInstance := client.DeserializeInstance(server)
+	providerInstance := deserializeInstance(project, server)
+	projectClient.CheckInstanceFault(ctx, server, &providerInstance)
 
 	return providerInstance, nil
 }
 
 func (a *HcloudProvider) ListInstances(ctx context.Context, poolID string) ([]params.ProviderInstance, error) {
-	servers, err := a.client.GetAllInstances(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get instances: %w", err)
-	}
 	var providerInstances []params.ProviderInstance
-	for _, server := range servers {
-		for key, value := range server.Labels {
-			if key == "GARM_POOL_ID" && value == poolID {
-				providerInstances = append(providerInstances, client.DeserializeInstance(server))
-				break
-			}
+	for _, project := range a.projectNames() {
+		projectClient, err := a.clientFor(project)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get instances: %w", err)
+		}
+		servers, err := projectClient.GetInstancesByLabels(ctx, map[string]string{
+			"GARM_POOL_ID":       poolID,
+			"GARM_CONTROLLER_ID": a.controllerID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get instances: %w", err)
+		}
+		for _, server := range servers {
+			providerInstance := deserializeInstance(project, server)
+			projectClient.CheckInstanceFault(ctx, server, &providerInstance)
+			providerInstances = append(providerInstances, providerInstance)
 		}
 	}
 	return providerInstances, nil
 }
 
 func (a *HcloudProvider) RemoveAllInstances(ctx context.Context) error {
-	return nil
+	var errs []error
+	for _, project := range a.projectNames() {
+		projectClient, err := a.clientFor(project)
+		if err == nil {
+			err = projectClient.RemoveAllInstances(ctx, a.controllerID)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove instances: %w", err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func (a *HcloudProvider) Stop(ctx context.Context, instance string, force bool) error {
-	return a.client.StopInstance(ctx, instance)
+	project, instance := splitProviderID(instance)
+	projectClient, err := a.clientFor(project)
+	if err != nil {
+		return err
+	}
+	return projectClient.StopInstance(ctx, instance, force)
 }
 
 func (a *HcloudProvider) Start(ctx context.Context, instance string) error {
-	return a.client.StartInstance(ctx, instance)
+	project, instance := splitProviderID(instance)
+	projectClient, err := a.clientFor(project)
+	if err != nil {
+		return err
+	}
+	return projectClient.StartInstance(ctx, instance)
 }
 
 func (a *HcloudProvider) GetVersion(ctx context.Context) string {
@@ -26,6 +26,7 @@ import (
 	"github.com/imtf-group/garm-provider-hetzner/internal/spec"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"net"
 	"strconv"
 	"testing"
 )
@@ -71,7 +72,7 @@ func TestCreateInstance(t *testing.T) {
 		client:       &client.HcloudClient{},
 	}
 	config := &config.Config{
-		Location: "nbg1",
+		Location: config.Locations{"nbg1"},
 		Token:    "mysecret",
 	}
 	provider.client.SetConfig(config)
@@ -79,14 +80,98 @@ func TestCreateInstance(t *testing.T) {
 
 	serverID, err := strconv.ParseInt(providerID, 10, 64)
 	assert.NoError(t, err)
+	mockAPI.On("GetServersByLabel", ctx, "GARM_CONTROLLER_ID=controllerID,GARM_POOL_ID=my-pool").Return([]*hcloud.Server{}, nil)
+	mockAPI.On("GetServerType", ctx, "cx22").Return(&hcloud.ServerType{
+		Name:         "cx22",
+		Architecture: hcloud.ArchitectureX86,
+	}, &hcloud.Response{}, nil)
+	mockAPI.On("GetImage", ctx, "ubuntu-22.04", hcloud.ArchitectureX86).Return(&hcloud.Image{
+		ID:           67794396,
+		Name:         "ubuntu-22.04",
+		Architecture: hcloud.ArchitectureX86,
+	}, &hcloud.Response{}, nil)
 	mockAPI.On("CreateServer", ctx, mock.Anything).Return(hcloud.ServerCreateResult{
 		Server: &hcloud.Server{
-			ID: serverID,
+			ID:     serverID,
+			Status: hcloud.ServerStatusInitializing,
+		},
+	}, &hcloud.Response{}, nil)
+	result, err := provider.CreateInstance(ctx, bootstrapParams)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedInstance, result)
+}
+
+func TestCreateInstanceWaitForCreate(t *testing.T) {
+	ctx := context.Background()
+	spec.DefaultToolFetch = func(osType params.OSType, osArch params.OSArch, tools []params.RunnerApplicationDownload) (params.RunnerApplicationDownload, error) {
+		return params.RunnerApplicationDownload{
+			OS:           hcloud.Ptr("linux"),
+			Architecture: hcloud.Ptr("amd64"),
+			DownloadURL:  hcloud.Ptr("MockURL"),
+			Filename:     hcloud.Ptr("garm-runner"),
+		}, nil
+	}
+	bootstrapParams := params.BootstrapInstance{
+		Name:       "garm-instance",
+		Flavor:     "cx22",
+		Image:      "ubuntu-22.04",
+		OSType:     params.Linux,
+		OSArch:     params.Amd64,
+		PoolID:     "my-pool",
+		ExtraSpecs: json.RawMessage(`{}`),
+	}
+	expectedInstance := params.ProviderInstance{
+		ProviderID: "123456",
+		Name:       "garm-instance",
+		OSType:     "linux",
+		OSArch:     "amd64",
+		Status:     params.InstanceRunning,
+		Addresses: []params.Address{
+			{Address: "192.0.2.10", Type: params.PublicAddress},
+		},
+	}
+	mockAPI := new(client.MockHCloudAPI)
+	provider := &HcloudProvider{
+		controllerID: "controllerID",
+		client:       &client.HcloudClient{},
+	}
+	config := &config.Config{
+		Location:      config.Locations{"nbg1"},
+		Token:         "mysecret",
+		WaitForCreate: true,
+	}
+	provider.client.SetConfig(config)
+	provider.client.SetApi(mockAPI)
+
+	mockAPI.On("GetServersByLabel", ctx, "GARM_CONTROLLER_ID=controllerID,GARM_POOL_ID=my-pool").Return([]*hcloud.Server{}, nil)
+	mockAPI.On("GetServerType", ctx, "cx22").Return(&hcloud.ServerType{
+		Name:         "cx22",
+		Architecture: hcloud.ArchitectureX86,
+	}, &hcloud.Response{}, nil)
+	mockAPI.On("GetImage", ctx, "ubuntu-22.04", hcloud.ArchitectureX86).Return(&hcloud.Image{
+		ID:           67794396,
+		Name:         "ubuntu-22.04",
+		Architecture: hcloud.ArchitectureX86,
+	}, &hcloud.Response{}, nil)
+	mockAPI.On("CreateServer", ctx, mock.Anything).Return(hcloud.ServerCreateResult{
+		Server: &hcloud.Server{
+			ID:     123456,
+			Status: hcloud.ServerStatusInitializing,
+		},
+		Action: &hcloud.Action{ID: 1},
+	}, &hcloud.Response{}, nil)
+	mockAPI.On("WaitForActions", mock.Anything, []*hcloud.Action{{ID: 1}}).Return(nil)
+	mockAPI.On("GetServer", ctx, "123456").Return(&hcloud.Server{
+		ID:     123456,
+		Status: hcloud.ServerStatusRunning,
+		PublicNet: hcloud.ServerPublicNet{
+			IPv4: hcloud.ServerPublicNetIPv4{IP: net.ParseIP("192.0.2.10")},
 		},
 	}, &hcloud.Response{}, nil)
 	result, err := provider.CreateInstance(ctx, bootstrapParams)
 	assert.NoError(t, err)
 	assert.Equal(t, expectedInstance, result)
+	mockAPI.AssertExpectations(t)
 }
 
 func TestCreateInstanceError(t *testing.T) {
@@ -123,7 +208,7 @@ func TestCreateInstanceError(t *testing.T) {
 		client:       &client.HcloudClient{},
 	}
 	config := &config.Config{
-		Location: "nbg1",
+		Location: config.Locations{"nbg1"},
 		Token:    "mysecret",
 	}
 	provider.client.SetConfig(config)
@@ -131,6 +216,16 @@ func TestCreateInstanceError(t *testing.T) {
 
 	serverID, err := strconv.ParseInt(providerID, 10, 64)
 	assert.NoError(t, err)
+	mockAPI.On("GetServersByLabel", ctx, "GARM_CONTROLLER_ID=controllerID,GARM_POOL_ID=my-pool").Return([]*hcloud.Server{}, nil)
+	mockAPI.On("GetServerType", ctx, "cx22").Return(&hcloud.ServerType{
+		Name:         "cx22",
+		Architecture: hcloud.ArchitectureX86,
+	}, &hcloud.Response{}, nil)
+	mockAPI.On("GetImage", ctx, "ubuntu-22.04", hcloud.ArchitectureX86).Return(&hcloud.Image{
+		ID:           67794396,
+		Name:         "ubuntu-22.04",
+		Architecture: hcloud.ArchitectureX86,
+	}, &hcloud.Response{}, nil)
 	mockAPI.On("CreateServer", ctx, mock.Anything).Return(hcloud.ServerCreateResult{
 		Server: &hcloud.Server{
 			ID: serverID,
@@ -150,7 +245,7 @@ func TestDeleteInstance(t *testing.T) {
 		client:       &client.HcloudClient{},
 	}
 	config := &config.Config{
-		Location: "nbg1",
+		Location: config.Locations{"nbg1"},
 		Token:    "mysecret",
 	}
 	provider.client.SetConfig(config)
@@ -177,7 +272,7 @@ func TestDeleteInstanceError(t *testing.T) {
 		client:       &client.HcloudClient{},
 	}
 	config := &config.Config{
-		Location: "nbg1",
+		Location: config.Locations{"nbg1"},
 		Token:    "mysecret",
 	}
 	provider.client.SetConfig(config)
@@ -223,7 +318,7 @@ func TestGetInstance(t *testing.T) {
 		client:       &client.HcloudClient{},
 	}
 	config := &config.Config{
-		Location: "nbg1",
+		Location: config.Locations{"nbg1"},
 		Token:    "mysecret",
 	}
 	provider.client.SetConfig(config)
@@ -254,7 +349,7 @@ func TestGetInstanceError(t *testing.T) {
 		client:       &client.HcloudClient{},
 	}
 	config := &config.Config{
-		Location: "nbg1",
+		Location: config.Locations{"nbg1"},
 		Token:    "mysecret",
 	}
 	provider.client.SetConfig(config)
@@ -268,6 +363,51 @@ func TestGetInstanceError(t *testing.T) {
 	mockAPI.AssertExpectations(t)
 }
 
+func TestGetInstanceProviderFault(t *testing.T) {
+	ctx := context.Background()
+	providerID := "123456"
+	server := &hcloud.Server{
+		ID:     123456,
+		Status: hcloud.ServerStatusInitializing,
+		Labels: map[string]string{
+			"Name":         "garm-0000",
+			"GARM_POOL_ID": "09876-54321",
+			"OSType":       "linux",
+			"OSArch":       "amd64",
+		},
+	}
+	expectedInstance := params.ProviderInstance{
+		ProviderID:    "123456",
+		Name:          "garm-0000",
+		Status:        params.InstanceError,
+		OSType:        "linux",
+		OSArch:        "amd64",
+		ProviderFault: []byte("action create_server failed: cannot boot server (server_error)"),
+	}
+	mockAPI := new(client.MockHCloudAPI)
+	provider := &HcloudProvider{
+		controllerID: "controllerID",
+		client:       &client.HcloudClient{},
+	}
+	config := &config.Config{
+		Location: config.Locations{"nbg1"},
+		Token:    "mysecret",
+	}
+	provider.client.SetConfig(config)
+	provider.client.SetApi(mockAPI)
+	mockAPI.On("GetServer", ctx, providerID).Return(server, &hcloud.Response{}, nil)
+	mockAPI.On("GetLatestServerAction", ctx, server).Return(&hcloud.Action{
+		Command:      "create_server",
+		Status:       hcloud.ActionStatusError,
+		ErrorCode:    "server_error",
+		ErrorMessage: "cannot boot server",
+	}, &hcloud.Response{}, nil)
+	instance, err := provider.GetInstance(ctx, providerID)
+	assert.NoError(t, err)
+	assert.Equal(t, instance, expectedInstance)
+	mockAPI.AssertExpectations(t)
+}
+
 func TestListInstances(t *testing.T) {
 	ctx := context.Background()
 	servers := []*hcloud.Server{
@@ -291,16 +431,6 @@ func TestListInstances(t *testing.T) {
 				"OSArch":       "amd64",
 			},
 		},
-		&hcloud.Server{
-			ID:     234567,
-			Status: hcloud.ServerStatusOff,
-			Labels: map[string]string{
-				"Name":         "garm-0002",
-				"GARM_POOL_ID": "09876-54322",
-				"OSType":       "linux",
-				"OSArch":       "amd64",
-			},
-		},
 	}
 	expectedInstances := []params.ProviderInstance{
 		params.ProviderInstance{
@@ -324,16 +454,17 @@ func TestListInstances(t *testing.T) {
 		client:       &client.HcloudClient{},
 	}
 	config := &config.Config{
-		Location: "nbg1",
+		Location: config.Locations{"nbg1"},
 		Token:    "mysecret",
 	}
 	provider.client.SetConfig(config)
 	provider.client.SetApi(mockAPI)
-	mockAPI.On("GetAllServers", ctx).Return(servers, nil)
+	mockAPI.On("GetServersByLabel", ctx, "GARM_CONTROLLER_ID=controllerID,GARM_POOL_ID=09876-54321").Return(servers, nil)
 	instances, err := provider.ListInstances(ctx, "09876-54321")
 	assert.NoError(t, err)
 	assert.Equal(t, instances, expectedInstances)
 	mockAPI.AssertExpectations(t)
+	mockAPI.AssertNotCalled(t, "GetLatestServerAction", mock.Anything, mock.Anything)
 }
 
 func TestListInstancesError(t *testing.T) {
@@ -359,14 +490,40 @@ func TestListInstancesError(t *testing.T) {
 				"OSArch":       "amd64",
 			},
 		},
+	}
+	mockAPI := new(client.MockHCloudAPI)
+	provider := &HcloudProvider{
+		controllerID: "controllerID",
+		client:       &client.HcloudClient{},
+	}
+	config := &config.Config{
+		Location: config.Locations{"nbg1"},
+		Token:    "mysecret",
+	}
+	provider.client.SetConfig(config)
+	provider.client.SetApi(mockAPI)
+	mockAPI.On("GetServersByLabel", ctx, "GARM_CONTROLLER_ID=controllerID,GARM_POOL_ID=09876-54321").Return(servers, fmt.Errorf("error while listing instances"))
+	_, err := provider.ListInstances(ctx, "09876-54321")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "error while listing instances")
+	mockAPI.AssertExpectations(t)
+}
+
+func TestRemoveAllInstances(t *testing.T) {
+	ctx := context.Background()
+	servers := []*hcloud.Server{
 		&hcloud.Server{
-			ID:     234567,
-			Status: hcloud.ServerStatusOff,
+			ID: 123456,
 			Labels: map[string]string{
-				"Name":         "garm-0002",
-				"GARM_POOL_ID": "09876-54322",
-				"OSType":       "linux",
-				"OSArch":       "amd64",
+				"Name":               "garm-0000",
+				"GARM_CONTROLLER_ID": "controllerID",
+			},
+		},
+		&hcloud.Server{
+			ID: 234567,
+			Labels: map[string]string{
+				"Name":               "garm-0001",
+				"GARM_CONTROLLER_ID": "controllerID",
 			},
 		},
 	}
@@ -376,13 +533,34 @@ func TestListInstancesError(t *testing.T) {
 		client:       &client.HcloudClient{},
 	}
 	config := &config.Config{
-		Location: "nbg1",
+		Location: config.Locations{"nbg1"},
 		Token:    "mysecret",
 	}
 	provider.client.SetConfig(config)
 	provider.client.SetApi(mockAPI)
-	mockAPI.On("GetAllServers", ctx).Return(servers, fmt.Errorf("error while listing instances"))
-	_, err := provider.ListInstances(ctx, "09876-54321")
+	mockAPI.On("GetServersByLabel", ctx, "GARM_CONTROLLER_ID=controllerID").Return(servers, nil)
+	mockAPI.On("DeleteServer", ctx, servers[0]).Return(&hcloud.Response{}, nil)
+	mockAPI.On("DeleteServer", ctx, servers[1]).Return(&hcloud.Response{}, nil)
+	err := provider.RemoveAllInstances(ctx)
+	assert.NoError(t, err)
+	mockAPI.AssertExpectations(t)
+}
+
+func TestRemoveAllInstancesError(t *testing.T) {
+	ctx := context.Background()
+	mockAPI := new(client.MockHCloudAPI)
+	provider := &HcloudProvider{
+		controllerID: "controllerID",
+		client:       &client.HcloudClient{},
+	}
+	config := &config.Config{
+		Location: config.Locations{"nbg1"},
+		Token:    "mysecret",
+	}
+	provider.client.SetConfig(config)
+	provider.client.SetApi(mockAPI)
+	mockAPI.On("GetServersByLabel", ctx, "GARM_CONTROLLER_ID=controllerID").Return([]*hcloud.Server{}, fmt.Errorf("error while listing instances"))
+	err := provider.RemoveAllInstances(ctx)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "error while listing instances")
 	mockAPI.AssertExpectations(t)
@@ -397,7 +575,7 @@ func TestStop(t *testing.T) {
 		client:       &client.HcloudClient{},
 	}
 	config := &config.Config{
-		Location: "nbg1",
+		Location: config.Locations{"nbg1"},
 		Token:    "mysecret",
 	}
 	provider.client.SetConfig(config)
@@ -417,6 +595,32 @@ func TestStop(t *testing.T) {
 	mockAPI.AssertExpectations(t)
 }
 
+func TestStopGraceful(t *testing.T) {
+	ctx := context.Background()
+	providerID := "123456"
+	mockAPI := new(client.MockHCloudAPI)
+	provider := &HcloudProvider{
+		controllerID: "controllerID",
+		client:       &client.HcloudClient{},
+	}
+	config := &config.Config{
+		Location: config.Locations{"nbg1"},
+		Token:    "mysecret",
+	}
+	provider.client.SetConfig(config)
+	provider.client.SetApi(mockAPI)
+	server := &hcloud.Server{
+		ID:     123456,
+		Status: hcloud.ServerStatusRunning,
+	}
+	mockAPI.On("GetServer", ctx, providerID).Return(server, &hcloud.Response{}, nil)
+	mockAPI.On("ShutdownServer", ctx, server).Return(&hcloud.Action{}, &hcloud.Response{}, fmt.Errorf("locked"))
+	mockAPI.On("StopServer", ctx, server).Return(&hcloud.Action{}, &hcloud.Response{}, nil)
+	err := provider.Stop(ctx, providerID, false)
+	assert.NoError(t, err)
+	mockAPI.AssertExpectations(t)
+}
+
 func TestStopError(t *testing.T) {
 	ctx := context.Background()
 	providerID := "123456"
@@ -426,7 +630,7 @@ func TestStopError(t *testing.T) {
 		client:       &client.HcloudClient{},
 	}
 	config := &config.Config{
-		Location: "nbg1",
+		Location: config.Locations{"nbg1"},
 		Token:    "mysecret",
 	}
 	provider.client.SetConfig(config)
@@ -456,7 +660,7 @@ func TestStopStopped(t *testing.T) {
 		client:       &client.HcloudClient{},
 	}
 	config := &config.Config{
-		Location: "nbg1",
+		Location: config.Locations{"nbg1"},
 		Token:    "mysecret",
 	}
 	provider.client.SetConfig(config)
@@ -468,9 +672,9 @@ func TestStopStopped(t *testing.T) {
 		Status: hcloud.ServerStatusOff,
 	}, &hcloud.Response{}, nil)
 	err = provider.Stop(ctx, providerID, true)
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "cannot be stopped in off state")
+	assert.NoError(t, err)
 	mockAPI.AssertExpectations(t)
+	mockAPI.AssertNotCalled(t, "StopServer", mock.Anything, mock.Anything)
 }
 
 func TestStart(t *testing.T) {
@@ -482,7 +686,7 @@ func TestStart(t *testing.T) {
 		client:       &client.HcloudClient{},
 	}
 	config := &config.Config{
-		Location: "nbg1",
+		Location: config.Locations{"nbg1"},
 		Token:    "mysecret",
 	}
 	provider.client.SetConfig(config)
@@ -511,7 +715,7 @@ func TestStartError(t *testing.T) {
 		client:       &client.HcloudClient{},
 	}
 	config := &config.Config{
-		Location: "nbg1",
+		Location: config.Locations{"nbg1"},
 		Token:    "mysecret",
 	}
 	provider.client.SetConfig(config)
@@ -541,7 +745,7 @@ func TestStartRunning(t *testing.T) {
 		client:       &client.HcloudClient{},
 	}
 	config := &config.Config{
-		Location: "nbg1",
+		Location: config.Locations{"nbg1"},
 		Token:    "mysecret",
 	}
 	provider.client.SetConfig(config)
@@ -553,9 +757,9 @@ func TestStartRunning(t *testing.T) {
 		Status: hcloud.ServerStatusRunning,
 	}, &hcloud.Response{}, nil)
 	err = provider.Start(ctx, providerID)
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "cannot be started in running state")
+	assert.NoError(t, err)
 	mockAPI.AssertExpectations(t)
+	mockAPI.AssertNotCalled(t, "StartServer", mock.Anything, mock.Anything)
 }
 
 // func TestStop(t *testing.T) {